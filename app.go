@@ -1,20 +1,28 @@
 package golf
 
 import (
+	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"path"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Application is an abstraction of a Golf application, can be used for
 // configuration, etc.
 type Application struct {
-	router *router
+	// The router is replaced by a modified copy when a route is added or
+	// removed, so that requests are routed without locking.
+	router atomic.Value
 
-	// A map of string slices as value to indicate the static files.
-	staticRouter map[string][]string
+	// Held while modifying the routes, no modification is allowed once the
+	// application is frozen.
+	mu     sync.RWMutex
+	frozen bool
 
 	// The View model of the application. View handles the templating and page
 	// rendering.
@@ -31,71 +39,297 @@ type Application struct {
 	// MiddlewareChain is the middlewares that Golf uses.
 	middlewareChain *Chain
 
+	// The middlewares running after routing, and the HandlerFunc chaining
+	// them, swapped atomically when a middleware is added.
+	routedChain   *Chain
+	routedHandler atomic.Value
+
 	pool sync.Pool
 
-	errorHandler map[int]ErrorHandlerFunc
+	// The map[int]ErrorHandlerFunc of the error handlers, copied and swapped
+	// atomically when one is registered.
+	errorHandler atomic.Value
+
+	// Routes in the order of registration.
+	routes []*Route
+
+	// Routes registered with a name, used for building URLs.
+	namedRoutes map[string]*Route
 
 	// The default error handler, if the corresponding error code is not specified
 	// in the `errorHandler` map, this handler will be called.
 	DefaultErrorHandler ErrorHandlerFunc
 
-	handlerChain HandlerFunc
+	// The HandlerFunc chaining the middlewares, swapped atomically when one is
+	// added.
+	handlerChain atomic.Value
+
+	// The application this one is mounted in, and the prefix it is mounted
+	// under.
+	parent      *Application
+	mountPrefix string
+
+	// TrailingSlash indicates how a path not matching any route because of
+	// its ending slash is handled.
+	TrailingSlash TrailingSlashPolicy
+
+	// CleanPath redirects the requests whose path contains `//`, `./` or
+	// `../` to the cleaned path, if it matches a route.
+	CleanPath bool
+
+	// FixPathCase redirects the requests whose path only matches a route
+	// case-insensitively to the path of the route.
+	FixPathCase bool
+
+	// UseRawPath routes the requests on their escaped path when it differs
+	// from the default encoding of the path, e.g. `/files/a%2Fb`, and
+	// unescapes each parameter value. A parameter can then contain an encoded
+	// slash without being split into two segments. The static parts of the
+	// patterns are matched against the escaped path in this case.
+	UseRawPath bool
+
+	// RoutesBeforeStatic makes the routes take precedence over the static
+	// files, which are then only searched for the requests not matching any
+	// route. By default a static file is served even if a route matches.
+	RoutesBeforeStatic bool
+
+	// Debug enables the development diagnostics, the default 404 and 405
+	// error pages then explain why the request did not match any route.
+	Debug bool
+
+	// PanicOnRouteConflict makes the registration of a route panic when it
+	// conflicts with a route registered before. Otherwise a warning is logged
+	// and the new route replaces the old one.
+	PanicOnRouteConflict bool
 }
 
 // New is used for creating a new Golf Application instance.
 func New() *Application {
 	app := new(Application)
-	app.router = newRouter()
-	app.staticRouter = make(map[string][]string)
+	app.router.Store(newRouter())
 	app.View = NewView()
+	app.View.FuncMap["url_for"] = app.URLFor
 	app.Config = NewConfig()
-	app.errorHandler = make(map[int]ErrorHandlerFunc)
+	app.errorHandler.Store(make(map[int]ErrorHandlerFunc))
+	app.namedRoutes = make(map[string]*Route)
 	app.middlewareChain = NewChain()
+	app.routedChain = NewChain()
+	app.routedHandler.Store(app.routedChain.Final(app.dispatch))
 	app.DefaultErrorHandler = defaultErrorHandler
 	app.pool.New = func() interface{} {
 		return new(Context)
 	}
-	app.handlerChain = app.middlewareChain.Final(app.handler)
+	app.handlerChain.Store(app.middlewareChain.Final(app.handler))
 	return app
 }
 
 // Use appends a middleware to the existing middleware chain.
 func (app *Application) Use(m ...MiddlewareHandlerFunc) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.checkFrozen()
 	for _, fn := range m {
 		app.middlewareChain.Append(fn)
 	}
-	app.handlerChain = app.middlewareChain.Final(app.handler)
+	app.handlerChain.Store(app.middlewareChain.Final(app.handler))
+}
+
+// UseRouted appends a middleware running after routing, `ctx.Route` is set to
+// the matched route when it runs, or nil if no route matches the request. The
+// static files are served before routing, without these middlewares.
+func (app *Application) UseRouted(m ...MiddlewareHandlerFunc) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.checkFrozen()
+	for _, fn := range m {
+		app.routedChain.Append(fn)
+	}
+	app.routedHandler.Store(app.routedChain.Final(app.dispatch))
 }
 
 // First search if any of the static route matches the request.
-// If not, look up the URL in the router.
+// If not, look up the URL in the router. The static files are searched after
+// the router instead if RoutesBeforeStatic is set. The static fallback files
+// are served last.
 func (app *Application) handler(ctx *Context) {
-	for prefix, staticPathSlice := range app.staticRouter {
-		if strings.HasPrefix(ctx.Request.URL.Path, prefix) {
-			for _, staticPath := range staticPathSlice {
-				filePath := path.Join(staticPath, ctx.Request.URL.Path[len(prefix):])
-				fileInfo, err := os.Stat(filePath)
-				if err == nil && !fileInfo.IsDir() {
-					staticHandler(ctx, filePath)
-					return
-				}
-			}
+	if !app.RoutesBeforeStatic && app.serveStatic(ctx) {
+		return
+	}
+
+	hostname := normalizeHost(ctx.Request.Host)
+	host := app.loadRouter().matchHost(hostname)
+	reqPath, escaped := app.routingPath(ctx.Request)
+	handler, params, err := app.findRoute(host, hostname, ctx.Request.Method, reqPath, ctx.paramValues)
+	if err != nil && app.TrailingSlash == TrailingSlashMatch && len(reqPath) > 1 {
+		handler, params, err = app.findRoute(host, hostname, ctx.Request.Method, toggleSlash(reqPath), ctx.paramValues)
+	}
+	if err == nil {
+		if escaped {
+			unescapeValues(params.values)
 		}
+		ctx.paramValues = params.values
+		ctx.Params = params
+		ctx.Route = params.route
+		ctx.handler = handler
+	} else if (app.RoutesBeforeStatic && app.serveStatic(ctx)) || app.serveFallback(ctx) {
+		return
 	}
+	app.routedHandler.Load().(HandlerFunc)(ctx)
+	ctx.IsSent = true
+}
 
-	handler, params, err := app.router.FindRoute(ctx.Request.Method, ctx.Request.URL.Path)
+// routingPath returns the path a request is routed on, and whether it is the
+// escaped path.
+func (app *Application) routingPath(r *http.Request) (string, bool) {
+	if app.UseRawPath && r.URL.RawPath != "" {
+		return r.URL.EscapedPath(), true
+	}
+	return r.URL.Path, false
+}
+
+// unescapeValues decodes the parameter values matched on an escaped path.
+func unescapeValues(values []string) {
+	for i, value := range values {
+		if strings.IndexByte(value, '%') == -1 {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			values[i] = unescaped
+		}
+	}
+}
+
+// Calls the handler of the matched route, or answers the request when no
+// route matches.
+func (app *Application) dispatch(ctx *Context) {
+	if ctx.handler != nil {
+		ctx.handler(ctx)
+		return
+	}
+	hostname := normalizeHost(ctx.Request.Host)
+	host := app.loadRouter().matchHost(hostname)
+	if !app.fixPath(ctx, host, hostname) {
+		app.handleNotFound(ctx, host)
+	}
+}
+
+// Looks up a route in the router of the matched host first, then in the routes
+// registered without host. HEAD requests fall back to the GET routes, and all
+// the requests fall back to the routes registered with `Any`.
+func (app *Application) findRoute(host *hostRouter, hostname, method, path string, values []string) (HandlerFunc, Parameter, error) {
+	handler, params, err := app.findMethodRoute(host, hostname, method, path, values)
+	if err != nil && method == "HEAD" {
+		if handler, params, err = app.findMethodRoute(host, hostname, "GET", path, values); err == nil {
+			handler = headHandler(handler)
+		}
+	}
 	if err != nil {
-		app.handleError(ctx, 404)
-	} else {
-		ctx.Params = params
+		handler, params, err = app.findMethodRoute(host, hostname, anyMethod, path, values)
+	}
+	return handler, params, err
+}
+
+func (app *Application) findMethodRoute(host *hostRouter, hostname, method, path string, values []string) (HandlerFunc, Parameter, error) {
+	if host != nil {
+		if handler, params, err := host.find(method, path, values); err == nil {
+			params.host = host
+			params.hostname = hostname
+			return handler, params, nil
+		}
+	}
+	return app.loadRouter().find(method, path, values)
+}
+
+// headResponseWriter discards the response body, the headers are held back
+// until the handler returns so that the Content-Length of the discarded body
+// can be set.
+type headResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	length     int
+}
+
+func (w *headResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	w.length += len(b)
+	return len(b), nil
+}
+
+func (w *headResponseWriter) finish() {
+	if w.statusCode == 0 {
+		w.statusCode = 200
+	}
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" {
+		header.Set("Content-Length", strconv.Itoa(w.length))
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+}
+
+// headHandler wraps a GET handler to serve a HEAD request, the handler runs as
+// usual but its response body is discarded.
+func headHandler(handler HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		w := &headResponseWriter{ResponseWriter: ctx.Response}
+		ctx.Response = w
+		finished := false
+		defer func() {
+			// Restore the original writer in case of panic, so that the error
+			// page can still be sent.
+			ctx.Response = w.ResponseWriter
+			if finished {
+				w.finish()
+			}
+		}()
 		handler(ctx)
+		finished = true
 	}
-	ctx.IsSent = true
 }
 
-// Serve a static file
-func staticHandler(ctx *Context, filePath string) {
-	http.ServeFile(ctx.Response, ctx.Request, filePath)
+// Answers a request without any matching route. If the path matches routes
+// of other methods, answer OPTIONS requests with the allowed methods and other
+// requests with a 405 Method Not Allowed error. Otherwise it is a 404 error.
+func (app *Application) handleNotFound(ctx *Context, host *hostRouter) {
+	reqPath, _ := app.routingPath(ctx.Request)
+	paths := []string{reqPath}
+	if app.TrailingSlash != TrailingSlashStrict && len(reqPath) > 1 {
+		// The routes matching the path with or without the ending slash.
+		paths = append(paths, toggleSlash(reqPath))
+	}
+	var allowed []string
+	for _, p := range paths {
+		methods := app.loadRouter().AllowedMethods(p)
+		if host != nil {
+			methods = append(methods, host.AllowedMethods(p)...)
+		}
+		for _, method := range methods {
+			if !containsString(allowed, method) {
+				allowed = append(allowed, method)
+			}
+		}
+	}
+	sort.Strings(allowed)
+	statusCode := 404
+	if len(allowed) > 0 {
+		ctx.SetHeader("Allow", strings.Join(allowed, ", "))
+		if ctx.Request.Method == "OPTIONS" {
+			ctx.SendStatus(204)
+			return
+		}
+		statusCode = 405
+	}
+	if app.Debug {
+		app.handleError(ctx, statusCode, map[string]interface{}{
+			"Diagnostics": app.diagnose(ctx, host, allowed),
+		})
+		return
+	}
+	app.handleError(ctx, statusCode)
 }
 
 // Basic entrance of an `http.ResponseWriter` and an `http.Request`.
@@ -105,7 +339,7 @@ func (app *Application) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	ctx.Request = req
 	ctx.Response = res
 	ctx.App = app
-	app.handlerChain(ctx)
+	app.handlerChain.Load().(HandlerFunc)(ctx)
 	app.pool.Put(ctx)
 }
 
@@ -125,60 +359,265 @@ func (app *Application) RunTLS(addr, certFile, keyFile string) {
 	}
 }
 
-// Static is used for registering a static folder
-func (app *Application) Static(url string, path string) {
-	url = strings.TrimRight(url, "/")
-	app.staticRouter[url] = append(app.staticRouter[url], path)
-}
-
 // Get method is used for registering a Get method route
-func (app *Application) Get(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("GET", pattern, handler)
+func (app *Application) Get(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "GET", pattern, handler, nil, options...)
 }
 
 // Post method is used for registering a Post method route
-func (app *Application) Post(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("POST", pattern, handler)
+func (app *Application) Post(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "POST", pattern, handler, nil, options...)
 }
 
 // Put method is used for registering a Put method route
-func (app *Application) Put(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("PUT", pattern, handler)
+func (app *Application) Put(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "PUT", pattern, handler, nil, options...)
 }
 
 // Delete method is used for registering a Delete method route
-func (app *Application) Delete(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("DELETE", pattern, handler)
+func (app *Application) Delete(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "DELETE", pattern, handler, nil, options...)
 }
 
 // Patch method is used for registering a Patch method route
-func (app *Application) Patch(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("PATCH", pattern, handler)
+func (app *Application) Patch(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "PATCH", pattern, handler, nil, options...)
 }
 
 // Options method is used for registering a Options method route
-func (app *Application) Options(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("OPTIONS", pattern, handler)
+func (app *Application) Options(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "OPTIONS", pattern, handler, nil, options...)
 }
 
 // Head method is used for registering a Head method route
-func (app *Application) Head(pattern string, handler HandlerFunc) {
-	app.router.AddRoute("HEAD", pattern, handler)
+func (app *Application) Head(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", "HEAD", pattern, handler, nil, options...)
+}
+
+// Handle registers a route for the given method, which can be any method
+// including the non-standard ones, e.g. PROPFIND or PURGE.
+func (app *Application) Handle(method string, pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", checkMethod(method), pattern, handler, nil, options...)
+}
+
+// Match registers a route for each of the given methods. The name set by the
+// route options is given to the first route only.
+func (app *Application) Match(methods []string, pattern string, handler HandlerFunc, options ...RouteOption) []*Route {
+	return app.addRoutes("", methods, pattern, handler, nil, options...)
+}
+
+// Any registers a route matching all the methods. A route registered for a
+// specific method takes precedence over it.
+func (app *Application) Any(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return app.addRoute("", anyMethod, pattern, handler, nil, options...)
+}
+
+// Registers a route. The middlewares given, then the ones added by the route
+// options, are chained with the handler once here.
+func (app *Application) addRoute(host string, method string, pattern string, handler HandlerFunc, middleware []MiddlewareHandlerFunc, options ...RouteOption) *Route {
+	r := app.newRoute(host, method, pattern, handler, middleware, options...)
+	app.insertRoute(r, false)
+	return r
+}
+
+// Registers a route for each of the methods. The routes share the same URL,
+// the name set by the options is only given to the first one.
+func (app *Application) addRoutes(host string, methods []string, pattern string, handler HandlerFunc, middleware []MiddlewareHandlerFunc, options ...RouteOption) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, method := range methods {
+		r := app.newRoute(host, checkMethod(method), pattern, handler, middleware, options...)
+		if i > 0 {
+			r.name = ""
+		}
+		app.insertRoute(r, false)
+		routes[i] = r
+	}
+	return routes
+}
+
+func (app *Application) newRoute(host string, method string, pattern string, handler HandlerFunc, middleware []MiddlewareHandlerFunc, options ...RouteOption) *Route {
+	r := &Route{
+		Host:       host,
+		Method:     method,
+		Pattern:    pattern,
+		handler:    handler,
+		middleware: append([]MiddlewareHandlerFunc{}, middleware...),
+		app:        app,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Inserts a route into a copy of the router, which then replaces the router
+// used by the requests. Conflicts are reported unless replace is true.
+func (app *Application) insertRoute(r *Route, replace bool) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.checkFrozen()
+
+	router := app.loadRouter().clone(r.Host)
+	target := router
+	if r.Host != "" {
+		target = router.host(r.Host).router
+	}
+	// The routes replaced on all of their paths are dropped, a route with
+	// optional parameters keeps serving its paths which are not replaced.
+	replaced := make(map[*Route]int)
+	var dropped []*Route
+	for _, err := range target.addRoute(r.Method, r.Pattern, NewChain(r.middleware...).Final(r.handler), r) {
+		if !replace {
+			if app.PanicOnRouteConflict {
+				panic(err)
+			}
+			log.Printf("[Warning] %v", err)
+		}
+		if existing := err.existing; existing != nil {
+			if replaced[existing]++; replaced[existing] == len(optionalPatterns(existing.Pattern)) {
+				dropped = append(dropped, existing)
+			}
+		}
+	}
+	if other, ok := app.namedRoutes[r.name]; ok && other != r && !containsRoute(dropped, other) {
+		panic(fmt.Errorf("Route name %q is already used by %s %s", r.name, other.Method, other.Pattern))
+	}
+
+	app.router.Store(router)
+	for _, existing := range dropped {
+		app.dropRoute(existing)
+	}
+	if r.name != "" {
+		app.namedRoutes[r.name] = r
+	}
+	app.routes = append(app.routes, r)
+}
+
+func containsRoute(routes []*Route, r *Route) bool {
+	for _, route := range routes {
+		if route == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes a route from the route list and the named routes.
+func (app *Application) dropRoute(r *Route) {
+	routes := make([]*Route, 0, len(app.routes))
+	for _, route := range app.routes {
+		if route != r {
+			routes = append(routes, route)
+		}
+	}
+	app.routes = routes
+	r.removed = true
+	if app.namedRoutes[r.name] == r {
+		delete(app.namedRoutes, r.name)
+	}
+}
+
+// ReplaceRoute registers a route for the given method, or for all the methods
+// if it is "*", replacing the route registered on the same method and path
+// without reporting a conflict. It can be called while the application is
+// serving requests.
+func (app *Application) ReplaceRoute(method string, pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	r := app.newRoute("", routeMethod(method), pattern, handler, nil, options...)
+	app.insertRoute(r, true)
+	return r
+}
+
+// RemoveRoute removes the route registered for the method and the pattern, the
+// method is "*" for the routes registered with `Any`. It can be called while
+// the application is serving requests, an error is returned if there is no
+// such route.
+func (app *Application) RemoveRoute(method string, pattern string) error {
+	return app.removeRoute("", routeMethod(method), pattern)
+}
+
+func (app *Application) removeRoute(host string, method string, pattern string) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.checkFrozen()
+
+	router := app.loadRouter().clone(host)
+	target := router
+	if host != "" {
+		target = nil
+		for _, h := range router.hosts {
+			if h.pattern == host {
+				target = h.router
+			}
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("Route not found: %s %s", method, host+pattern)
+	}
+	r, ok := target.removeRoute(method, pattern)
+	if !ok {
+		return fmt.Errorf("Route not found: %s %s", method, host+pattern)
+	}
+	app.router.Store(router)
+	if r != nil {
+		app.dropRoute(r)
+	}
+	return nil
+}
+
+// routeMethod validates the method of a route to replace or remove, which can
+// be the method of the routes registered with `Any`.
+func routeMethod(method string) string {
+	if method == anyMethod {
+		return method
+	}
+	return checkMethod(method)
+}
+
+// Freeze forbids any further modification of the application, registering or
+// removing a route, adding a middleware, a static directory or an error
+// handler panics afterwards. The modifications do not race with the requests
+// being served, Freeze makes sure none happens once the application is set up.
+func (app *Application) Freeze() {
+	app.mu.Lock()
+	app.frozen = true
+	app.mu.Unlock()
+}
+
+// checkFrozen panics if the application is frozen, it is called with app.mu
+// held.
+func (app *Application) checkFrozen() {
+	if app.frozen {
+		panic(fmt.Errorf("Application is frozen, it can not be modified anymore"))
+	}
+}
+
+// loadRouter returns the router currently serving the requests.
+func (app *Application) loadRouter() *router {
+	return app.router.Load().(*router)
 }
 
 // Error method is used for registering an handler for a specified HTTP error code.
 func (app *Application) Error(statusCode int, handler ErrorHandlerFunc) {
-	app.errorHandler[statusCode] = handler
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.checkFrozen()
+	current := app.errorHandler.Load().(map[int]ErrorHandlerFunc)
+	handlers := make(map[int]ErrorHandlerFunc, len(current)+1)
+	for code, h := range current {
+		handlers[code] = h
+	}
+	handlers[statusCode] = handler
+	app.errorHandler.Store(handlers)
 }
 
 // Handles a HTTP Error, if there is a corresponding handler set in the map
 // `errorHandler`, then call it. Otherwise call the `defaultErrorHandler`.
 func (app *Application) handleError(ctx *Context, statusCode int, data ...map[string]interface{}) {
 	ctx.SendStatus(statusCode)
-	handler, ok := app.errorHandler[statusCode]
+	handler, ok := app.errorHandler.Load().(map[int]ErrorHandlerFunc)[statusCode]
 	if !ok {
 		defaultErrorHandler(ctx, data...)
 		return
 	}
-	handler(ctx)
+	handler(ctx, data...)
 }
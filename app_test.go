@@ -0,0 +1,361 @@
+package golf
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", handler)
+	app.Put("/users/:id", handler)
+	app.Error(405, func(ctx *Context, data ...map[string]interface{}) {
+		ctx.Send("not allowed")
+	})
+
+	_, _, r, w := makeTestContext("POST", "/users/1")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertEqual(t, "GET, HEAD, OPTIONS, PUT", w.Header().Get("Allow"))
+	assertEqual(t, "not allowed", w.Body.String())
+
+	_, _, r, w = makeTestContext("POST", "/posts/1")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+	assertEqual(t, "", w.Header().Get("Allow"))
+}
+
+func TestAutomaticOptions(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", handler)
+	app.Post("/users", handler)
+	app.Options("/users", func(ctx *Context) {
+		ctx.SetHeader("Allow", "POST")
+		ctx.Send("custom")
+	})
+
+	_, _, r, w := makeTestContext("OPTIONS", "/users/1")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 204, w.Code)
+	assertEqual(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+
+	_, _, r, w = makeTestContext("OPTIONS", "/users")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 200, w.Code)
+	assertEqual(t, "POST", w.Header().Get("Allow"))
+	assertEqual(t, "custom", w.Body.String())
+
+	_, _, r, w = makeTestContext("OPTIONS", "/posts")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+}
+
+func TestImplicitHead(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", func(ctx *Context) {
+		ctx.SetHeader("X-User", ctx.Param("id"))
+		ctx.SendStatus(201)
+		ctx.Send("user " + ctx.Param("id"))
+	})
+
+	_, _, r, w := makeTestContext("HEAD", "/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 201, w.Code)
+	assertEqual(t, "42", w.Header().Get("X-User"))
+	assertEqual(t, "7", w.Header().Get("Content-Length"))
+	assertEqual(t, "", w.Body.String())
+
+	_, _, r, w = makeTestContext("HEAD", "/posts/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+}
+
+func TestExplicitHead(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", func(ctx *Context) { ctx.Send("get") })
+	app.Head("/users/:id", func(ctx *Context) { ctx.SetHeader("X-Handler", "head") })
+
+	_, _, r, w := makeTestContext("HEAD", "/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 200, w.Code)
+	assertEqual(t, "head", w.Header().Get("X-Handler"))
+}
+
+func TestPanicOnRouteConflict(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", handler)
+	app.Get("/users/:id", handler)
+
+	app.PanicOnRouteConflict = true
+	defer func() {
+		err := recover()
+		if _, ok := err.(*RouteConflictError); !ok {
+			t.Errorf("Route conflict should panic with a RouteConflictError, got %v", err)
+		}
+	}()
+	app.Get("/users/:name", handler)
+}
+
+func TestOptionalParameterConflicts(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	app := New()
+	app.Get("/posts", func(ctx *Context) { ctx.Send("list") }, Named("list"))
+	app.Get("/posts/:id", func(ctx *Context) { ctx.Send("show") }, Named("show"))
+	app.Get("/posts/:page?", func(ctx *Context) { ctx.Send("page") })
+
+	assertContains(t, logs.String(), `GET /posts/:page\? \(.*\) conflicts with GET /posts \(.*\) on /posts\n`)
+	assertContains(t, logs.String(), `GET /posts/:page\? \(.*\) conflicts with GET /posts/:id \(.*\) on /posts/:page\n`)
+	assertEqual(t, 1, len(app.Routes()))
+	_, err := app.URLFor("list")
+	assertError(t, err)
+	_, err = app.URLFor("show", "id", 3)
+	assertError(t, err)
+
+	app = New()
+	app.Get("/posts", handler)
+	app.Get("/posts/:id", handler)
+	app.PanicOnRouteConflict = true
+	func() {
+		defer func() {
+			if _, ok := recover().(*RouteConflictError); !ok {
+				t.Errorf("Route conflict should panic with a RouteConflictError.")
+			}
+		}()
+		app.Get("/posts/:page?", handler)
+	}()
+	assertEqual(t, 2, len(app.Routes()))
+}
+
+func TestOptionalParameterPartialConflict(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	app := New()
+	app.Get("/posts/:page?", func(ctx *Context) { ctx.Send("page " + ctx.Param("page")) }, Named("posts"))
+	app.Get("/posts", func(ctx *Context) { ctx.Send("list") })
+	assertContains(t, logs.String(), `GET /posts \(.*\) conflicts with GET /posts/:page\? \(.*\) on /posts\n`)
+
+	for path, expected := range map[string]string{
+		"/posts":   "list",
+		"/posts/3": "page 3",
+	} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, 200, w.Code)
+		assertEqual(t, expected, w.Body.String())
+	}
+	assertEqual(t, 2, len(app.Routes()))
+	url, err := app.URLFor("posts", "page", 3)
+	assertNoError(t, err)
+	assertEqual(t, "/posts/3", url)
+}
+
+func methodHandler(ctx *Context) {
+	ctx.Send(ctx.Request.Method)
+}
+
+func TestHandleCustomMethod(t *testing.T) {
+	app := New()
+	app.Handle("PROPFIND", "/dav/*path", methodHandler)
+	app.Group("/cache").Handle("PURGE", "/:key", methodHandler)
+
+	_, _, r, w := makeTestContext("PROPFIND", "/dav/a/b")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "PROPFIND", w.Body.String())
+
+	_, _, r, w = makeTestContext("PURGE", "/cache/home")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "PURGE", w.Body.String())
+
+	_, _, r, w = makeTestContext("GET", "/cache/home")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertEqual(t, "OPTIONS, PURGE", w.Header().Get("Allow"))
+
+	for _, method := range []string{"", "*", "GET /"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Invalid method should raise an error: %q", method)
+				}
+			}()
+			app.Handle(method, "/", handler)
+		}()
+	}
+}
+
+func TestMatchMethods(t *testing.T) {
+	app := New()
+	routes := app.Match([]string{"GET", "POST"}, "/form", methodHandler)
+	assertEqual(t, 2, len(routes))
+
+	for _, method := range []string{"GET", "POST", "HEAD"} {
+		_, _, r, w := makeTestContext(method, "/form")
+		app.ServeHTTP(w, r)
+		assertEqual(t, 200, w.Code)
+	}
+
+	_, _, r, w := makeTestContext("PUT", "/form")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertEqual(t, "GET, HEAD, OPTIONS, POST", w.Header().Get("Allow"))
+}
+
+func TestMatchNamed(t *testing.T) {
+	app := New()
+	routes := app.Match([]string{"GET", "POST"}, "/form/:id", methodHandler, Named("form"))
+	assertEqual(t, "form", routes[0].RouteName())
+	assertEqual(t, "", routes[1].RouteName())
+	url, err := app.URLFor("form", "id", 3)
+	assertNoError(t, err)
+	assertEqual(t, "/form/3", url)
+
+	routes = app.Group("/api").Match([]string{"PUT", "PATCH"}, "/items/:id", methodHandler, Named("item"))
+	assertEqual(t, "item", routes[0].RouteName())
+	url, err = app.URLFor("item", "id", 7)
+	assertNoError(t, err)
+	assertEqual(t, "/api/items/7", url)
+}
+
+func TestAnyMethod(t *testing.T) {
+	app := New()
+	app.Any("/proxy/*path", methodHandler)
+	app.Get("/proxy/status", func(ctx *Context) { ctx.Send("status") })
+	app.Post("/items", handler)
+
+	for _, method := range []string{"GET", "POST", "DELETE", "PURGE", "OPTIONS"} {
+		_, _, r, w := makeTestContext(method, "/proxy/a")
+		app.ServeHTTP(w, r)
+		assertEqual(t, method, w.Body.String())
+	}
+
+	_, _, r, w := makeTestContext("GET", "/proxy/status")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "status", w.Body.String())
+
+	_, _, r, w = makeTestContext("POST", "/proxy/status")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "POST", w.Body.String())
+
+	_, _, r, w = makeTestContext("GET", "/items")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertEqual(t, "OPTIONS, POST", w.Header().Get("Allow"))
+	assertEqual(t, "*", app.Routes()[0].Method)
+}
+
+func TestRouteOnContext(t *testing.T) {
+	app := New()
+	var seen []string
+	app.UseRouted(func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if ctx.Route == nil {
+				seen = append(seen, "none")
+			} else {
+				seen = append(seen, ctx.Route.Method+" "+ctx.Route.Pattern+" "+ctx.Route.RouteName()+" "+ctx.Route.Tag("policy"))
+			}
+			next(ctx)
+		}
+	})
+	app.Get("/users/:id", func(ctx *Context) {
+		assertEqual(t, "/users/:id", ctx.Route.Pattern)
+	}, Named("user.show"), Tag("policy", "public"))
+	app.Post("/users", handler)
+
+	for _, req := range [][2]string{
+		{"GET", "/users/42"},
+		{"HEAD", "/users/42/"},
+		{"POST", "/users"},
+		{"GET", "/posts"},
+	} {
+		_, _, r, w := makeTestContext(req[0], req[1])
+		app.ServeHTTP(w, r)
+	}
+	assertDeepEqual(t, []string{
+		"GET /users/:id user.show public",
+		"GET /users/:id user.show public",
+		"POST /users  ",
+		"none",
+	}, seen)
+}
+
+func TestUseRoutedOrder(t *testing.T) {
+	app := New()
+	app.Use(headerMiddleware("X-Order", "global"))
+	app.Get("/", handler, With(headerMiddleware("X-Order", "route")))
+	app.UseRouted(headerMiddleware("X-Order", "routed"))
+
+	_, _, r, w := makeTestContext("GET", "/")
+	app.ServeHTTP(w, r)
+	assertDeepEqual(t, []string{"global", "routed", "route"}, w.Header()["X-Order"])
+
+	_, _, r, w = makeTestContext("GET", "/missing")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+	assertDeepEqual(t, []string{"global", "routed"}, w.Header()["X-Order"])
+}
+
+func TestUseRawPath(t *testing.T) {
+	app := New()
+	app.Get("/files/:id", func(ctx *Context) { ctx.Send("file " + ctx.Param("id")) })
+	app.Get("/docs/*path", func(ctx *Context) { ctx.Send("doc " + ctx.Param("path")) })
+	app.Get("/files/:id/meta", func(ctx *Context) { ctx.Send("meta " + ctx.Param("id")) })
+
+	_, _, r, w := makeTestContext("GET", "/files/a%2Fb")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+
+	app.UseRawPath = true
+	for path, expected := range map[string]string{
+		"/files/a%2Fb":           "file a/b",
+		"/files/a%2Fb/meta":      "meta a/b",
+		"/files/caf%C3%A9%2F1":   "file café/1",
+		"/files/a%20b":           "file a b",
+		"/docs/a%2Fb/c%20d.md":   "doc a/b/c d.md",
+		"/docs/guide/intro.md":   "doc guide/intro.md",
+		"/files/100%25%2Fsecret": "file 100%/secret",
+	} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, expected, w.Body.String())
+	}
+
+	_, _, r, w = makeTestContext("POST", "/files/a%2Fb")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertEqual(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestOptionalParameter(t *testing.T) {
+	app := New()
+	app.Get("/posts/:page<int>?", func(ctx *Context) {
+		page, ok := ctx.LookupParam("page")
+		ctx.Send(fmt.Sprintf("page=%q present=%v", page, ok))
+	})
+
+	for path, expected := range map[string]string{
+		"/posts":   `page="" present=false`,
+		"/posts/":  `page="" present=false`,
+		"/posts/3": `page="3" present=true`,
+	} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, expected, w.Body.String())
+	}
+	assertEqual(t, 1, len(app.Routes()))
+
+	app.PanicOnRouteConflict = true
+	defer func() {
+		if err := recover(); err == nil {
+			t.Errorf("Route conflicting with an optional parameter route should raise an error.")
+		}
+	}()
+	app.Get("/posts", handler)
+}
@@ -23,6 +23,17 @@ type Context struct {
 	// URL Parameter
 	Params Parameter
 
+	// The route matched by the request, nil if no route matches. It is set
+	// before the middlewares added with `Application.UseRouted` run.
+	Route *Route
+
+	// The handler of the matched route.
+	handler HandlerFunc
+
+	// The buffer the values of the URL parameters are captured into, it is
+	// reused by the following requests.
+	paramValues []string
+
 	// HTTP status code
 	statusCode int
 
@@ -56,10 +67,13 @@ func NewContext(req *http.Request, res http.ResponseWriter, app *Application) *C
 func (ctx *Context) reset() {
 	ctx.statusCode = 200
 	ctx.IsSent = false
+	ctx.Params = Parameter{}
+	ctx.Route = nil
+	ctx.handler = nil
 }
 
 func (ctx *Context) generateSession() Session {
-	s, err := ctx.App.SessionManager.NewSession()
+	s, err := ctx.App.sessionManager().NewSession()
 	if err != nil {
 		return nil
 	}
@@ -74,7 +88,7 @@ func (ctx *Context) retrieveSession() {
 	if err != nil {
 		s = ctx.generateSession()
 	} else {
-		s, err = ctx.App.SessionManager.Session(sid)
+		s, err = ctx.App.sessionManager().Session(sid)
 		if err != nil {
 			s = ctx.generateSession()
 		}
@@ -126,6 +140,14 @@ func (ctx *Context) Param(key string) string {
 	return val
 }
 
+// LookupParam retrieves a parameter from the url like Param, the boolean is
+// false if the parameter is absent, e.g. the optional parameter of
+// `/posts/:page?` when `/posts` is requested.
+func (ctx *Context) LookupParam(key string) (string, bool) {
+	val, err := ctx.Params.ByName(key)
+	return val, err == nil
+}
+
 // Redirect method sets the response as a 302 redirection.
 func (ctx *Context) Redirect(url string) {
 	ctx.SetHeader("Location", url)
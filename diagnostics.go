@@ -0,0 +1,214 @@
+package golf
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The maximum number of closest routes listed by the diagnostics.
+const maxClosestRoutes = 5
+
+// RoutingDiagnostics explains why a request did not match any route. When
+// `Application.Debug` is set, it is given to the 404 and 405 error handlers
+// as "Diagnostics" and rendered by the default error page.
+type RoutingDiagnostics struct {
+	Method string
+	Path   string
+
+	// The methods of the routes matching the path.
+	AllowedMethods []string
+
+	// The registered routes closest to the path, the closest first.
+	ClosestRoutes []RouteInfo
+
+	// The static directories, and whether they were checked for the path.
+	StaticDirs []StaticDiagnostic
+}
+
+// StaticDiagnostic describes the static directories registered for a prefix.
+type StaticDiagnostic struct {
+	Prefix  string
+	Dirs    []string
+	Checked bool
+}
+
+// Looks for the reasons a request did not match any route.
+func (app *Application) diagnose(ctx *Context, host *hostRouter, allowed []string) *RoutingDiagnostics {
+	reqPath, _ := app.routingPath(ctx.Request)
+	diagnostics := &RoutingDiagnostics{
+		Method:         ctx.Request.Method,
+		Path:           reqPath,
+		AllowedMethods: allowed,
+	}
+
+	type candidate struct {
+		info     RouteInfo
+		distance int
+		prefix   int
+	}
+	var candidates []candidate
+	for _, info := range app.Routes() {
+		if info.Host != "" && (host == nil || info.Host != host.pattern) {
+			continue
+		}
+		// A third of the static characters of the pattern may be mistyped.
+		maxDistance := staticLength(info.Pattern) / 3
+		if maxDistance < 2 {
+			maxDistance = 2
+		}
+		if d := routeDistance(info.Pattern, reqPath); d <= maxDistance {
+			candidates = append(candidates, candidate{info, d, commonPrefix(staticPrefix(info.Pattern), reqPath)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].prefix > candidates[j].prefix
+	})
+	for i := 0; i < len(candidates) && i < maxClosestRoutes; i++ {
+		diagnostics.ClosestRoutes = append(diagnostics.ClosestRoutes, candidates[i].info)
+	}
+
+	for _, m := range app.loadRouter().mounts {
+		static := StaticDiagnostic{
+			Prefix:  m.prefix,
+			Checked: ctx.Request.URL.Path == m.prefix || strings.HasPrefix(ctx.Request.URL.Path, m.prefix+"/"),
+		}
+		for _, root := range m.roots {
+			static.Dirs = append(static.Dirs, root.name)
+		}
+		diagnostics.StaticDirs = append(diagnostics.StaticDirs, static)
+	}
+	sort.Slice(diagnostics.StaticDirs, func(i, j int) bool {
+		return diagnostics.StaticDirs[i].Prefix < diagnostics.StaticDirs[j].Prefix
+	})
+	return diagnostics
+}
+
+// staticPrefix returns the part of a pattern before its first parameter.
+func staticPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, ":*"); i != -1 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// staticLength returns the number of characters of the static segments of a
+// pattern.
+func staticLength(pattern string) int {
+	length := 0
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment != "" && segment[0] != ':' && segment[0] != '*' {
+			length += utf8.RuneCountInString(segment)
+		}
+	}
+	return length
+}
+
+// routeDistance returns the number of edits needed for a path to match a
+// route pattern. The static segments are compared with the edit distance, a
+// parameter segment matches any segment satisfying its constraint.
+func routeDistance(pattern, path string) int {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if pathSegments[0] == "" {
+		pathSegments = nil
+	}
+	if patternSegments[0] == "" {
+		patternSegments = nil
+	}
+
+	distance := 0
+	for i, segment := range patternSegments {
+		if segment == "" {
+			// The empty segment of a pattern such as /a//b.
+			if i < len(pathSegments) {
+				distance += utf8.RuneCountInString(pathSegments[i])
+			}
+			continue
+		}
+		if segment[0] == '*' {
+			return distance
+		}
+		if i >= len(pathSegments) {
+			if segment[0] == ':' {
+				if segment[len(segment)-1] != '?' {
+					distance++
+				}
+			} else {
+				distance += utf8.RuneCountInString(segment)
+			}
+			continue
+		}
+		if segment[0] == ':' {
+			if re := segmentConstraint(segment); re != nil && !re.MatchString(pathSegments[i]) {
+				distance++
+			}
+			continue
+		}
+		distance += editDistance(segment, pathSegments[i])
+	}
+	for i := len(patternSegments); i < len(pathSegments); i++ {
+		distance += utf8.RuneCountInString(pathSegments[i])
+	}
+	return distance
+}
+
+// segmentConstraint returns the regular expression constraining a parameter
+// segment, or nil if it is not constrained.
+func segmentConstraint(segment string) *regexp.Regexp {
+	start := strings.IndexByte(segment, '<')
+	if start == -1 {
+		return nil
+	}
+	end := constraintEnd(segment, start)
+	if end == -1 {
+		return nil
+	}
+	expr := segment[start+1 : end]
+	if t, ok := paramTypes[expr]; ok {
+		expr = t
+	}
+	re, _ := compileConstraint(expr)
+	return re
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters needed to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	row := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = min3(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && prev2[j-2]+1 < row[j] {
+				row[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, row = prev, row, prev2
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,89 @@
+package golf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	assertEqual(t, 0, editDistance("users", "users"))
+	assertEqual(t, 1, editDistance("users", "usres"))
+	assertEqual(t, 3, editDistance("kitten", "sitting"))
+	assertEqual(t, 1, editDistance("user", "users"))
+	assertEqual(t, 5, editDistance("", "users"))
+	assertEqual(t, 1, editDistance("café", "cafe"))
+}
+
+func TestRouteDistance(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		expected      int
+	}{
+		{"/users/:id", "/users/42", 0},
+		{"/users/:id", "/usres/42", 1},
+		{"/users/:id<int>", "/users/abc", 1},
+		{"/users/:id", "/users", 1},
+		{"/posts/:page?", "/posts", 0},
+		{"/files/*path", "/files/a/b/c", 0},
+		{"/files/*path", "/filez/a/b/c", 1},
+		{"/about", "/about/team", 4},
+		{"/", "/", 0},
+		{"/a//b", "/a//b", 0},
+		{"/a//b", "/a/x/b", 1},
+		{"/a//b", "/zzz", 4},
+	}
+	for _, c := range cases {
+		assertEqual(t, c.expected, routeDistance(c.pattern, c.path))
+	}
+}
+
+func TestNotFoundDiagnostics(t *testing.T) {
+	app := New()
+	app.Debug = true
+	app.Static("/static/", "testdata")
+	app.Static("/assets/", "public")
+	app.Get("/users/:id", handler).Name("user.show")
+	app.Get("/users", handler)
+	app.Post("/orders", handler)
+	app.Get("/about", handler)
+
+	_, _, r, w := makeTestContext("GET", "/usres/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+	assertContains(t, w.Body.String(), `Closest routes to <code>GET /usres/42</code>`)
+	assertContains(t, w.Body.String(), `<li><code>GET /users/:id</code> user.show</li>\s+</ul>`)
+	assertContains(t, w.Body.String(), `<code>/assets</code> &rarr; <code>public</code> prefix not matching`)
+
+	_, _, r, w = makeTestContext("GET", "/static/missing.css")
+	app.ServeHTTP(w, r)
+	assertContains(t, w.Body.String(), `<code>/static</code> &rarr; <code>testdata</code> checked, no such file`)
+	assertContains(t, w.Body.String(), `No registered route is close`)
+
+	_, _, r, w = makeTestContext("GET", "/orders")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertContains(t, w.Body.String(), `is only registered for: <code>OPTIONS, POST</code>`)
+
+	var diagnostics *RoutingDiagnostics
+	app.Error(404, func(ctx *Context, data ...map[string]interface{}) {
+		diagnostics = data[0]["Diagnostics"].(*RoutingDiagnostics)
+	})
+	_, _, r, w = makeTestContext("GET", "/abuot")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "/abuot", diagnostics.Path)
+	assertEqual(t, "/about", diagnostics.ClosestRoutes[0].Pattern)
+
+	app = New()
+	app.Debug = true
+	app.Get("/a//b", handler)
+	_, _, r, w = makeTestContext("GET", "/zzz")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+
+	app = New()
+	app.Get("/users/:id", handler)
+	_, _, r, w = makeTestContext("GET", "/usres/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+	assertEqual(t, false, strings.Contains(w.Body.String(), "Closest routes"))
+}
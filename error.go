@@ -83,6 +83,30 @@ color: #ff8a00;
         <pre><code>{{ .Message }}</code></pre>
         <h2>HTTP Request</h2>
         <pre class="request-dump">{{ .HTTPRequest }}</pre>
+        {{ with .Diagnostics }}
+        <h2>Routing</h2>
+        {{ if .AllowedMethods }}
+        <p>The path <code>{{ .Path }}</code> is only registered for: <code>{{ range $i, $m := .AllowedMethods }}{{ if $i }}, {{ end }}{{ $m }}{{ end }}</code></p>
+        {{ end }}
+        {{ if .ClosestRoutes }}
+        <p>Closest routes to <code>{{ .Method }} {{ .Path }}</code>:</p>
+        <ul id="routes">
+          {{ range .ClosestRoutes }}
+          <li><code>{{ .Method }} {{ .Host }}{{ .Pattern }}</code> {{ .Name }}</li>
+          {{ end }}
+        </ul>
+        {{ else }}
+        <p>No registered route is close to <code>{{ .Method }} {{ .Path }}</code>.</p>
+        {{ end }}
+        {{ if .StaticDirs }}
+        <p>Static directories:</p>
+        <ul id="static">
+          {{ range .StaticDirs }}
+          <li><code>{{ .Prefix }}</code> &rarr; <code>{{ range $i, $d := .Dirs }}{{ if $i }}, {{ end }}{{ $d }}{{ end }}</code> {{ if .Checked }}checked, no such file{{ else }}prefix not matching{{ end }}</li>
+          {{ end }}
+        </ul>
+        {{ end }}
+        {{ end }}
         {{ if .StackTrace }}
         <h2>Traceback</h2>
         <ul id="backtrace">
@@ -0,0 +1,143 @@
+package golf
+
+import (
+	"strings"
+)
+
+// Group is a set of routes sharing a common URL prefix and middlewares. The
+// middlewares of a group only wrap the handlers registered through the group,
+// they are chained together when a route is registered.
+type Group struct {
+	app             *Application
+	host            string
+	prefix          string
+	middlewareChain *Chain
+}
+
+// Group creates a route group with the given prefix. The middlewares given
+// will only be applied to the routes registered through the group.
+func (app *Application) Group(prefix string, m ...MiddlewareHandlerFunc) *Group {
+	return &Group{
+		app:             app,
+		prefix:          groupPrefix(prefix),
+		middlewareChain: NewChain(m...),
+	}
+}
+
+// Group creates a nested group, the prefix and middlewares of the parent group
+// are inherited by the new one.
+func (g *Group) Group(prefix string, m ...MiddlewareHandlerFunc) *Group {
+	handlers := make([]MiddlewareHandlerFunc, 0, len(g.middlewareChain.middlewareHandlers)+len(m))
+	handlers = append(handlers, g.middlewareChain.middlewareHandlers...)
+	handlers = append(handlers, m...)
+	return &Group{
+		app:             g.app,
+		host:            g.host,
+		prefix:          g.prefix + groupPrefix(prefix),
+		middlewareChain: NewChain(handlers...),
+	}
+}
+
+// Use appends middlewares to the group. Only routes registered after calling
+// Use will be wrapped by them.
+func (g *Group) Use(m ...MiddlewareHandlerFunc) {
+	for _, fn := range m {
+		g.middlewareChain.Append(fn)
+	}
+}
+
+// Returns the prefix of a group starting with a slash, without the ending
+// one.
+func groupPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// Returns the full pattern of a route registered through the group, the
+// prefix and the pattern are separated with a slash.
+func (g *Group) pattern(pattern string) string {
+	if pattern == "" || pattern == "/" {
+		if g.prefix == "" {
+			return "/"
+		}
+		return g.prefix
+	}
+	if pattern[0] != '/' {
+		pattern = "/" + pattern
+	}
+	return g.prefix + pattern
+}
+
+func (g *Group) addRoute(method string, pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.app.addRoute(g.host, method, g.pattern(pattern), handler, g.middlewareChain.middlewareHandlers, options...)
+}
+
+// Get method is used for registering a Get method route
+func (g *Group) Get(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("GET", pattern, handler, options...)
+}
+
+// Post method is used for registering a Post method route
+func (g *Group) Post(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("POST", pattern, handler, options...)
+}
+
+// Put method is used for registering a Put method route
+func (g *Group) Put(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("PUT", pattern, handler, options...)
+}
+
+// Delete method is used for registering a Delete method route
+func (g *Group) Delete(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("DELETE", pattern, handler, options...)
+}
+
+// Patch method is used for registering a Patch method route
+func (g *Group) Patch(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("PATCH", pattern, handler, options...)
+}
+
+// Options method is used for registering a Options method route
+func (g *Group) Options(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("OPTIONS", pattern, handler, options...)
+}
+
+// Head method is used for registering a Head method route
+func (g *Group) Head(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute("HEAD", pattern, handler, options...)
+}
+
+// Handle registers a route for the given method, which can be any method
+// including the non-standard ones, e.g. PROPFIND or PURGE.
+func (g *Group) Handle(method string, pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute(checkMethod(method), pattern, handler, options...)
+}
+
+// Match registers a route for each of the given methods. The name set by the
+// route options is given to the first route only.
+func (g *Group) Match(methods []string, pattern string, handler HandlerFunc, options ...RouteOption) []*Route {
+	return g.app.addRoutes(g.host, methods, g.pattern(pattern), handler, g.middlewareChain.middlewareHandlers, options...)
+}
+
+// Any registers a route matching all the methods. A route registered for a
+// specific method takes precedence over it.
+func (g *Group) Any(pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	return g.addRoute(anyMethod, pattern, handler, options...)
+}
+
+// ReplaceRoute registers a route through the group, replacing the route
+// registered on the same method and path without reporting a conflict.
+func (g *Group) ReplaceRoute(method string, pattern string, handler HandlerFunc, options ...RouteOption) *Route {
+	r := g.app.newRoute(g.host, routeMethod(method), g.pattern(pattern), handler, g.middlewareChain.middlewareHandlers, options...)
+	g.app.insertRoute(r, true)
+	return r
+}
+
+// RemoveRoute removes a route registered through the group, or with the same
+// host and full pattern.
+func (g *Group) RemoveRoute(method string, pattern string) error {
+	return g.app.removeRoute(g.host, routeMethod(method), g.pattern(pattern))
+}
@@ -0,0 +1,85 @@
+package golf
+
+import (
+	"testing"
+)
+
+func headerMiddleware(key, value string) MiddlewareHandlerFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			ctx.AddHeader(key, value)
+			next(ctx)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	app := New()
+	admin := app.Group("/admin", headerMiddleware("X-Group", "admin"))
+	admin.Get("/", func(ctx *Context) { ctx.Send("index") })
+	admin.Get("/users/:id", func(ctx *Context) { ctx.Send("user " + ctx.Param("id")) })
+	app.Get("/users/:id", func(ctx *Context) { ctx.Send("public " + ctx.Param("id")) })
+
+	_, _, r, w := makeTestContext("GET", "/admin/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "user 42", w.Body.String())
+	assertEqual(t, "admin", w.Header().Get("X-Group"))
+
+	_, _, r, w = makeTestContext("GET", "/admin")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "index", w.Body.String())
+
+	_, _, r, w = makeTestContext("GET", "/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "public 42", w.Body.String())
+	assertEqual(t, "", w.Header().Get("X-Group"))
+}
+
+func TestNestedGroup(t *testing.T) {
+	app := New()
+	api := app.Group("/api/", headerMiddleware("X-Group", "api"))
+	v1 := api.Group("/v1", headerMiddleware("X-Group", "v1"))
+	v1.Post("/items", func(ctx *Context) { ctx.Send("created") })
+	api.Get("/status", func(ctx *Context) { ctx.Send("ok") })
+
+	_, _, r, w := makeTestContext("POST", "/api/v1/items")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "created", w.Body.String())
+	assertDeepEqual(t, []string{"api", "v1"}, w.Header()["X-Group"])
+
+	_, _, r, w = makeTestContext("GET", "/api/status")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "ok", w.Body.String())
+	assertDeepEqual(t, []string{"api"}, w.Header()["X-Group"])
+}
+
+func TestGroupPatternSeparator(t *testing.T) {
+	app := New()
+	api := app.Group("/api")
+	api.Get("users", handler)
+	api.Group("v1/").Get(":id", handler)
+	app.Group("").Get("about", handler)
+	app.Host("api.example.com").Get("status", handler)
+
+	var patterns []string
+	for _, info := range app.Routes() {
+		patterns = append(patterns, info.Pattern)
+	}
+	assertDeepEqual(t, []string{"/api/users", "/api/v1/:id", "/about", "/status"}, patterns)
+}
+
+func TestGroupUse(t *testing.T) {
+	app := New()
+	g := app.Group("/g")
+	g.Get("/before", func(ctx *Context) {})
+	g.Use(headerMiddleware("X-Group", "g"))
+	g.Get("/after", func(ctx *Context) {})
+
+	_, _, r, w := makeTestContext("GET", "/g/before")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "", w.Header().Get("X-Group"))
+
+	_, _, r, w = makeTestContext("GET", "/g/after")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "g", w.Header().Get("X-Group"))
+}
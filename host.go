@@ -0,0 +1,132 @@
+package golf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hostRouter holds the routes registered for a host pattern. A host pattern is
+// a domain name whose labels may be parameters, e.g. `:tenant.example.com`.
+type hostRouter struct {
+	*router
+	pattern string
+	labels  []string
+	names   map[string]int
+}
+
+func newHostRouter(pattern string) *hostRouter {
+	h := &hostRouter{
+		router:  newRouter(),
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+		names:   make(map[string]int),
+	}
+	for i, label := range h.labels {
+		if label == "" {
+			panic(fmt.Errorf("Invalid host pattern, empty label - %q", pattern))
+		}
+		if label[0] == ':' {
+			if len(label) == 1 {
+				panic(fmt.Errorf("Invalid host pattern, missing parameter name - %q", pattern))
+			}
+			h.names[label[1:]] = i
+		} else if strings.IndexByte(label, ':') != -1 {
+			panic(fmt.Errorf("Invalid host pattern, : should always start a label - %q", pattern))
+		}
+	}
+	return h
+}
+
+// match checks if the hostname matches the host pattern.
+func (h *hostRouter) match(hostname string) bool {
+	for _, label := range h.labels {
+		i := strings.IndexByte(hostname, '.')
+		value := hostname
+		if i != -1 {
+			value = hostname[:i]
+		}
+		if value == "" || (label[0] != ':' && label != value) {
+			return false
+		}
+		if i == -1 {
+			hostname = ""
+		} else {
+			hostname = hostname[i+1:]
+			if hostname == "" {
+				return false
+			}
+		}
+	}
+	return hostname == ""
+}
+
+// param returns the value of the host parameter with the given name.
+func (h *hostRouter) param(hostname string, name string) (string, bool) {
+	idx, ok := h.names[name]
+	if !ok {
+		return "", false
+	}
+	for i := 0; i < idx; i++ {
+		hostname = hostname[strings.IndexByte(hostname, '.')+1:]
+	}
+	if i := strings.IndexByte(hostname, '.'); i != -1 {
+		hostname = hostname[:i]
+	}
+	return hostname, true
+}
+
+// host returns the router of a host pattern, creating it if needed.
+func (router *router) host(pattern string) *hostRouter {
+	pattern = strings.ToLower(pattern)
+	for _, h := range router.hosts {
+		if h.pattern == pattern {
+			return h
+		}
+	}
+	h := newHostRouter(pattern)
+	router.hosts = append(router.hosts, h)
+	return h
+}
+
+// matchHost returns the router matching the hostname. Host patterns without
+// parameters take precedence, the others are tried in registration order.
+func (router *router) matchHost(hostname string) *hostRouter {
+	var matched *hostRouter
+	for _, h := range router.hosts {
+		if len(h.names) == 0 && h.pattern == hostname {
+			return h
+		}
+		if matched == nil && len(h.names) > 0 && h.match(hostname) {
+			matched = h
+		}
+	}
+	return matched
+}
+
+// normalizeHost strips the port of a request host and lowercases it.
+func normalizeHost(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i != -1 && strings.IndexByte(host[i:], ']') == -1 {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(host, ".")
+	for i := 0; i < len(host); i++ {
+		if 'A' <= host[i] && host[i] <= 'Z' {
+			return strings.ToLower(host)
+		}
+	}
+	return host
+}
+
+// Host creates a route group matching only the requests for the given host.
+// Labels of the host pattern starting with `:` are parameters, which can be
+// retrieved with `ctx.Param`, e.g. `app.Host(":tenant.example.com")`. Requests
+// not matching any host specific route fall back to the routes registered
+// without host.
+func (app *Application) Host(pattern string, m ...MiddlewareHandlerFunc) *Group {
+	newHostRouter(strings.ToLower(pattern))
+	return &Group{
+		app:             app,
+		host:            strings.ToLower(pattern),
+		middlewareChain: NewChain(m...),
+	}
+}
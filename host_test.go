@@ -0,0 +1,87 @@
+package golf
+
+import (
+	"testing"
+)
+
+func makeTestHostRequest(app *Application, method, host, path string) string {
+	_, _, r, w := makeTestContext(method, path)
+	r.Host = host
+	app.ServeHTTP(w, r)
+	return w.Body.String()
+}
+
+func TestHostRouting(t *testing.T) {
+	app := New()
+	app.Host("admin.example.com").Get("/", func(ctx *Context) { ctx.Send("admin") })
+	app.Host(":tenant.example.com").Get("/", func(ctx *Context) { ctx.Send("tenant " + ctx.Param("tenant")) })
+	app.Host(":tenant.example.com").Get("/users/:id", func(ctx *Context) {
+		ctx.Send(ctx.Param("tenant") + " user " + ctx.Param("id"))
+	})
+	app.Get("/", func(ctx *Context) { ctx.Send("default") })
+	app.Get("/about", func(ctx *Context) { ctx.Send("about") })
+
+	cases := []struct {
+		host, path, expected string
+	}{
+		{"admin.example.com", "/", "admin"},
+		{"Admin.Example.com:8080", "/", "admin"},
+		{"acme.example.com", "/", "tenant acme"},
+		{"acme.example.com", "/users/42", "acme user 42"},
+		{"acme.example.com", "/about", "about"},
+		{"example.com", "/", "default"},
+		{"a.b.example.com", "/", "default"},
+		{"localhost", "/", "default"},
+	}
+	for _, c := range cases {
+		assertEqual(t, c.expected, makeTestHostRequest(app, "GET", c.host, c.path))
+	}
+}
+
+func TestHostGroup(t *testing.T) {
+	app := New()
+	api := app.Host("api.example.com", headerMiddleware("X-Host", "api")).Group("/v1")
+	api.Get("/status", func(ctx *Context) { ctx.Send("ok") })
+
+	_, _, r, w := makeTestContext("GET", "/v1/status")
+	r.Host = "api.example.com"
+	app.ServeHTTP(w, r)
+	assertEqual(t, "ok", w.Body.String())
+	assertEqual(t, "api", w.Header().Get("X-Host"))
+
+	_, _, r, w = makeTestContext("GET", "/v1/status")
+	r.Host = "www.example.com"
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+
+	assertEqual(t, "api.example.com", app.Routes()[0].Host)
+}
+
+func TestHostPattern(t *testing.T) {
+	h := newHostRouter(":sub.:domain.com")
+	assertEqual(t, true, h.match("www.example.com"))
+	assertEqual(t, false, h.match("example.com"))
+	assertEqual(t, false, h.match("www.example.org"))
+	assertEqual(t, false, h.match("www..com"))
+	assertEqual(t, false, h.match("www.example.com."))
+	value, _ := h.param("www.example.com", "domain")
+	assertEqual(t, "example", value)
+
+	for _, pattern := range []string{"a..com", "a:b.com", ":.com"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Invalid host pattern should raise an error: %v", pattern)
+				}
+			}()
+			newHostRouter(pattern)
+		}()
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	assertEqual(t, "example.com", normalizeHost("Example.COM:80"))
+	assertEqual(t, "example.com", normalizeHost("example.com."))
+	assertEqual(t, "[::1]", normalizeHost("[::1]:8080"))
+	assertEqual(t, "[::1]", normalizeHost("[::1]"))
+}
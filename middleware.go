@@ -154,7 +154,7 @@ func RecoverMiddleware(next HandlerFunc) HandlerFunc {
 // SessionMiddleware handles session of the request
 func SessionMiddleware(next HandlerFunc) HandlerFunc {
 	fn := func(ctx *Context) {
-		if ctx.App.SessionManager != nil {
+		if ctx.App.sessionManager() != nil {
 			ctx.retrieveSession()
 		}
 		next(ctx)
@@ -0,0 +1,75 @@
+package golf
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Mount serves a standard http.Handler under the given prefix, the prefix is
+// stripped from the URL path before the request is passed to the handler.
+//
+// The handler can be another Golf Application, it keeps its own middlewares,
+// error handlers and View, and shares the SessionManager of the parent
+// application if it does not have one of its own.
+func (app *Application) Mount(prefix string, handler http.Handler) {
+	url := prefix
+	prefix = strings.TrimRight(prefix, "/")
+	if strings.ContainsAny(prefix, ":*") {
+		panic(Errorf("Invalid mount prefix, : and * are not allowed - %q", url))
+	}
+	if child, ok := handler.(*Application); ok {
+		if child == app {
+			panic(Errorf("Can not mount an application inside of itself"))
+		}
+		child.parent = app
+		child.mountPrefix = prefix
+	}
+	fn := mountHandler(prefix, handler)
+	if prefix != "" {
+		app.Any(prefix, fn)
+	}
+	app.Any(prefix+"/*path", fn)
+}
+
+// mountHandler returns a handler calling a http.Handler with the prefix
+// stripped from the request URL.
+func mountHandler(prefix string, handler http.Handler) HandlerFunc {
+	return func(ctx *Context) {
+		req := new(http.Request)
+		*req = *ctx.Request
+		u := *ctx.Request.URL
+		u.Path = strings.TrimPrefix(u.Path, prefix)
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		if u.RawPath != "" {
+			u.RawPath = strings.TrimPrefix(u.RawPath, prefix)
+			if u.RawPath == "" {
+				u.RawPath = "/"
+			}
+		}
+		req.URL = &u
+		handler.ServeHTTP(ctx.Response, req)
+	}
+}
+
+// urlPrefix returns the prefix of the URLs of the application, which is the
+// prefix it is mounted under inside of its parents.
+func (app *Application) urlPrefix() string {
+	prefix := ""
+	for a := app; a.parent != nil; a = a.parent {
+		prefix = a.mountPrefix + prefix
+	}
+	return prefix
+}
+
+// sessionManager returns the SessionManager of the application, or the one of
+// the parent application if the application is mounted and has none.
+func (app *Application) sessionManager() SessionManager {
+	for a := app; a != nil; a = a.parent {
+		if a.SessionManager != nil {
+			return a.SessionManager
+		}
+	}
+	return nil
+}
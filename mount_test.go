@@ -0,0 +1,119 @@
+package golf
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMountHandler(t *testing.T) {
+	app := New()
+	app.Mount("/debug/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	app.Get("/debugger", func(ctx *Context) { ctx.Send("debugger") })
+
+	cases := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/debug/pprof/heap?debug=1", "GET /pprof/heap?debug=1"},
+		{"POST", "/debug/vars", "POST /vars?"},
+		{"GET", "/debug/", "GET /?"},
+		{"GET", "/debug", "GET /?"},
+		{"OPTIONS", "/debug/x", "OPTIONS /x?"},
+		{"GET", "/debugger", "debugger"},
+	}
+	for _, c := range cases {
+		_, _, r, w := makeTestContext(c.method, c.path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, c.expected, w.Body.String())
+	}
+}
+
+func TestMountApplication(t *testing.T) {
+	app := New()
+	app.SessionManager = NewMemorySessionManager()
+	app.Use(headerMiddleware("X-App", "parent"))
+
+	admin := New()
+	admin.Use(SessionMiddleware, headerMiddleware("X-App", "admin"))
+	admin.Error(404, func(ctx *Context, data ...map[string]interface{}) {
+		ctx.Send("admin not found")
+	})
+	admin.Get("/users/:id", func(ctx *Context) {
+		if ctx.Session == nil {
+			t.Errorf("Mounted application should share the session manager")
+		}
+		ctx.Send("admin user " + ctx.Param("id"))
+	})
+	app.Mount("/admin", admin)
+
+	_, _, r, w := makeTestContext("GET", "/admin/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "admin user 42", w.Body.String())
+	assertDeepEqual(t, []string{"parent", "admin"}, w.Header()["X-App"])
+	assertEqual(t, 1, app.SessionManager.Count())
+
+	_, _, r, w = makeTestContext("GET", "/admin/posts")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+	assertEqual(t, "admin not found", w.Body.String())
+}
+
+func TestMountItself(t *testing.T) {
+	app := New()
+	defer func() {
+		if err := recover(); err == nil {
+			t.Errorf("Mounting an application inside of itself should raise an error.")
+		}
+	}()
+	app.Mount("/app", app)
+}
+
+func TestMountPrefixURLs(t *testing.T) {
+	admin := New()
+	admin.TrailingSlash = TrailingSlashRedirect
+	admin.FixPathCase = true
+	admin.Get("/users/", handler, Named("users"))
+	admin.Get("/users/:id", handler, Named("user"))
+
+	reports := New()
+	reports.Get("/daily", handler, Named("daily"))
+	admin.Mount("/reports", reports)
+
+	app := New()
+	app.Mount("/admin", admin)
+
+	for path, location := range map[string]string{
+		"/admin/users":        "/admin/users/",
+		"/admin/USERS/":       "/admin/users/",
+		"/admin/Users/a%3Fb":  "/admin/users/a%3Fb",
+		"/admin/users?page=2": "/admin/users/?page=2",
+	} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, 301, w.Code)
+		assertEqual(t, location, w.Header().Get("Location"))
+	}
+
+	url, err := admin.URLFor("user", "id", 7)
+	assertNoError(t, err)
+	assertEqual(t, "/admin/users/7", url)
+	url, err = reports.URLFor("daily")
+	assertNoError(t, err)
+	assertEqual(t, "/admin/reports/daily", url)
+	_, err = app.URLFor("unknown")
+	assertError(t, err)
+}
+
+func TestMountInvalidPrefix(t *testing.T) {
+	for _, prefix := range []string{"/u/:id", "/files/*path"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Mounting under %q should raise an error.", prefix)
+				}
+			}()
+			New().Mount(prefix, New())
+		}()
+	}
+}
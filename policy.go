@@ -0,0 +1,133 @@
+package golf
+
+import (
+	"net/url"
+	"path"
+)
+
+// TrailingSlashPolicy indicates how the router handles a path that does not
+// match a route only because of its ending slash.
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashMatch serves the route matching the path with or without
+	// the ending slash, e.g. `/users/` is served by `/users`.
+	TrailingSlashMatch TrailingSlashPolicy = iota
+
+	// TrailingSlashStrict only serves the route matching the exact path.
+	TrailingSlashStrict
+
+	// TrailingSlashRedirect redirects to the path of the matching route, with
+	// 301 for GET and HEAD requests and 308 for the other methods.
+	TrailingSlashRedirect
+)
+
+// toggleSlash adds the ending slash to a path, or removes it.
+func toggleSlash(p string) string {
+	if p[len(p)-1] == '/' {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// cleanPath returns the canonical form of a URL path, eliminating `//`, `./`
+// and `../` while keeping the ending slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if p[len(p)-1] == '/' && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// Tries to redirect a request whose path does not match any route according to
+// the TrailingSlash, CleanPath and FixPathCase settings. It returns false if
+// the request is left unanswered.
+func (app *Application) fixPath(ctx *Context, host *hostRouter, hostname string) bool {
+	method := ctx.Request.Method
+	reqPath, _ := app.routingPath(ctx.Request)
+
+	if app.TrailingSlash == TrailingSlashRedirect && len(reqPath) > 1 {
+		toggled := toggleSlash(reqPath)
+		if _, _, err := app.findRoute(host, hostname, method, toggled, nil); err == nil {
+			app.redirectPath(ctx, toggled)
+			return true
+		}
+	}
+
+	if app.CleanPath {
+		if cleaned := cleanPath(reqPath); cleaned != reqPath {
+			if _, _, err := app.findRoute(host, hostname, method, cleaned, nil); err == nil {
+				app.redirectPath(ctx, cleaned)
+				return true
+			}
+			if app.TrailingSlash != TrailingSlashStrict && len(cleaned) > 1 {
+				if _, _, err := app.findRoute(host, hostname, method, toggleSlash(cleaned), nil); err == nil {
+					app.redirectPath(ctx, toggleSlash(cleaned))
+					return true
+				}
+			}
+			reqPath = cleaned
+		}
+	}
+
+	if app.FixPathCase {
+		if fixed, ok := app.findCaseInsensitive(host, method, reqPath); ok {
+			app.redirectPath(ctx, fixed)
+			return true
+		}
+		if app.TrailingSlash != TrailingSlashStrict && len(reqPath) > 1 {
+			if fixed, ok := app.findCaseInsensitive(host, method, toggleSlash(reqPath)); ok {
+				app.redirectPath(ctx, fixed)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (app *Application) findCaseInsensitive(host *hostRouter, method, p string) (string, bool) {
+	methods := []string{method}
+	if method == "HEAD" {
+		methods = append(methods, "GET")
+	}
+	methods = append(methods, anyMethod)
+	for _, m := range methods {
+		if host != nil {
+			if fixed, ok := host.FindCaseInsensitive(m, p); ok {
+				return fixed, true
+			}
+		}
+		if fixed, ok := app.loadRouter().FindCaseInsensitive(m, p); ok {
+			return fixed, true
+		}
+	}
+	return "", false
+}
+
+// Redirects the request to the canonical path, keeping the query string. The
+// path is escaped unless it is already the escaped path of the request, and
+// prefixed with the prefix the application is mounted under.
+func (app *Application) redirectPath(ctx *Context, p string) {
+	if _, escaped := app.routingPath(ctx.Request); !escaped {
+		p = (&url.URL{Path: p}).EscapedPath()
+	}
+	if prefix := app.urlPrefix(); prefix != "" {
+		p = (&url.URL{Path: prefix}).EscapedPath() + p
+	}
+	if ctx.Request.URL.RawQuery != "" {
+		p += "?" + ctx.Request.URL.RawQuery
+	}
+	ctx.SetHeader("Location", p)
+	if ctx.Request.Method == "GET" || ctx.Request.Method == "HEAD" {
+		ctx.SendStatus(301)
+	} else {
+		ctx.SendStatus(308)
+	}
+}
@@ -0,0 +1,154 @@
+package golf
+
+import (
+	"testing"
+)
+
+func newPolicyTestApp() *Application {
+	app := New()
+	app.Get("/users", func(ctx *Context) { ctx.Send("users") })
+	app.Get("/users/:id/", func(ctx *Context) { ctx.Send("user " + ctx.Param("id")) })
+	app.Post("/users", func(ctx *Context) { ctx.Send("created") })
+	app.Get("/Docs/Intro", func(ctx *Context) { ctx.Send("intro") })
+	return app
+}
+
+func TestTrailingSlashMatch(t *testing.T) {
+	app := newPolicyTestApp()
+
+	_, _, r, w := makeTestContext("GET", "/users/")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 200, w.Code)
+	assertEqual(t, "users", w.Body.String())
+
+	_, _, r, w = makeTestContext("GET", "/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "user 42", w.Body.String())
+
+	_, _, r, w = makeTestContext("PUT", "/users/")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 405, w.Code)
+	assertEqual(t, "GET, HEAD, OPTIONS, POST", w.Header().Get("Allow"))
+
+	_, _, r, w = makeTestContext("OPTIONS", "/users/42")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 204, w.Code)
+	assertEqual(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestTrailingSlashStrict(t *testing.T) {
+	app := newPolicyTestApp()
+	app.TrailingSlash = TrailingSlashStrict
+
+	for _, path := range []string{"/users/", "/users/42"} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, 404, w.Code)
+	}
+
+	_, _, r, w := makeTestContext("PUT", "/users/")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+}
+
+func TestTrailingSlashRedirect(t *testing.T) {
+	app := newPolicyTestApp()
+	app.TrailingSlash = TrailingSlashRedirect
+
+	cases := []struct {
+		method, path, location string
+		code                   int
+	}{
+		{"GET", "/users/", "/users", 301},
+		{"GET", "/users/42?tab=posts", "/users/42/?tab=posts", 301},
+		{"HEAD", "/users/42", "/users/42/", 301},
+		{"POST", "/users/", "/users", 308},
+	}
+	for _, c := range cases {
+		_, _, r, w := makeTestContext(c.method, c.path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, c.code, w.Code)
+		assertEqual(t, c.location, w.Header().Get("Location"))
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	app := newPolicyTestApp()
+	app.TrailingSlash = TrailingSlashRedirect
+	app.CleanPath = true
+
+	cases := map[string]string{
+		"//users":               "/users",
+		"/docs/../users":        "/users",
+		"/./users/42/":          "/users/42/",
+		"/users//42":            "/users/42/",
+		"/users/7/../42/./":     "/users/42/",
+		"/users/../../users/42": "/users/42/",
+	}
+	for path, location := range cases {
+		_, _, r, w := makeTestContext("GET", "http://example.com")
+		r.URL.Path = path
+		app.ServeHTTP(w, r)
+		assertEqual(t, 301, w.Code)
+		assertEqual(t, location, w.Header().Get("Location"))
+	}
+
+	app.CleanPath = false
+	_, _, r, w := makeTestContext("GET", "http://example.com")
+	r.URL.Path = "//users"
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+}
+
+func TestFixPathCase(t *testing.T) {
+	app := newPolicyTestApp()
+	app.CleanPath = true
+	app.FixPathCase = true
+
+	cases := map[string]string{
+		"/USERS":           "/users",
+		"/Users/AbC/":      "/users/AbC/",
+		"/docs/intro":      "/Docs/Intro",
+		"/docs//INTRO":     "/Docs/Intro",
+		"/DOCS/intro/":     "/Docs/Intro",
+		"/users/42/../Abc": "/users/Abc/",
+	}
+	for path, location := range cases {
+		_, _, r, w := makeTestContext("GET", "http://example.com")
+		r.URL.Path = path
+		app.ServeHTTP(w, r)
+		assertEqual(t, 301, w.Code)
+		assertEqual(t, location, w.Header().Get("Location"))
+	}
+
+	_, _, r, w := makeTestContext("GET", "/USERS/a%3Fb%20c/?page=2")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 301, w.Code)
+	assertEqual(t, "/users/a%3Fb%20c/?page=2", w.Header().Get("Location"))
+
+	app.UseRawPath = true
+	_, _, r, w = makeTestContext("GET", "/USERS/a%2Fb/")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 301, w.Code)
+	assertEqual(t, "/users/a%2Fb/", w.Header().Get("Location"))
+	app.UseRawPath = false
+
+	app.FixPathCase = false
+	_, _, r, w = makeTestContext("GET", "/USERS")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+}
+
+func TestCleanPathFunc(t *testing.T) {
+	cases := map[string]string{
+		"":          "/",
+		"/":         "/",
+		"a/b":       "/a/b",
+		"/a//b/":    "/a/b/",
+		"/a/./b/..": "/a",
+		"/../a/":    "/a/",
+	}
+	for path, expected := range cases {
+		assertEqual(t, expected, cleanPath(path))
+	}
+}
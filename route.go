@@ -0,0 +1,289 @@
+package golf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+)
+
+// Route is a route registered in the application.
+type Route struct {
+	Host    string
+	Method  string
+	Pattern string
+
+	name       string
+	tags       map[string]string
+	handler    HandlerFunc
+	middleware []MiddlewareHandlerFunc
+	app        *Application
+
+	// Whether the route was removed or replaced, it is set with app.mu held.
+	removed bool
+}
+
+// RouteOption configures a route at registration.
+type RouteOption func(r *Route)
+
+// With adds middlewares to a route, they only wrap the handler of this route
+// and run after the middlewares of the group it is registered in, e.g.
+// `app.Post("/upload", handler, golf.With(auth, limit))`.
+func With(m ...MiddlewareHandlerFunc) RouteOption {
+	return func(r *Route) {
+		r.middleware = append(r.middleware, m...)
+	}
+}
+
+// Named sets the name of a route, it is the same as calling `Route.Name`.
+func Named(name string) RouteOption {
+	return func(r *Route) {
+		r.name = name
+	}
+}
+
+// Tag sets a user-defined tag on a route, tags can be read by middlewares from
+// `ctx.Route`, e.g. `app.Get("/reports", handler, golf.Tag("policy", "admin"))`.
+func Tag(key, value string) RouteOption {
+	return func(r *Route) {
+		if r.tags == nil {
+			r.tags = make(map[string]string)
+		}
+		r.tags[key] = value
+	}
+}
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Host       string            `json:"host,omitempty"`
+	Method     string            `json:"method"`
+	Pattern    string            `json:"pattern"`
+	Name       string            `json:"name,omitempty"`
+	Handler    string            `json:"handler"`
+	Middleware []string          `json:"middleware"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// RouteTable is a list of route descriptions, it can be printed as a text
+// table or encoded as JSON.
+type RouteTable []RouteInfo
+
+// Routes returns the description of all the routes in the order of
+// registration.
+func (app *Application) Routes() RouteTable {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	table := make(RouteTable, 0, len(app.routes))
+	for _, r := range app.routes {
+		info := RouteInfo{
+			Host:       r.Host,
+			Method:     r.Method,
+			Pattern:    r.Pattern,
+			Name:       r.name,
+			Handler:    funcName(r.handler),
+			Middleware: make([]string, len(r.middleware)),
+			Tags:       r.tags,
+		}
+		for i, m := range r.middleware {
+			info.Middleware[i] = funcName(m)
+		}
+		table = append(table, info)
+	}
+	return table
+}
+
+// String renders the routes as a text table.
+func (t RouteTable) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATTERN\tNAME\tHANDLER\tMIDDLEWARE")
+	for _, info := range t {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", info.Method, info.Host+info.Pattern, info.Name, info.Handler, strings.Join(info.Middleware, ", "))
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// JSON encodes the routes as indented JSON.
+func (t RouteTable) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// RoutesHandler is a handler responding with the route table of the
+// application in JSON, it can be registered on an internal debug endpoint.
+func RoutesHandler(ctx *Context) {
+	ctx.JSONIndent(ctx.App.Routes(), "", "  ")
+}
+
+// funcName returns the name of a function, or an empty string if it is nil.
+func funcName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	if f := runtime.FuncForPC(v.Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// Name sets the name of the route, the name can be used for building URLs
+// with `Application.URLFor` or `url_for` inside of templates. It panics if the
+// route was removed or replaced.
+func (r *Route) Name(name string) *Route {
+	r.app.mu.Lock()
+	defer r.app.mu.Unlock()
+	r.app.checkFrozen()
+	if r.removed {
+		panic(fmt.Errorf("Route %s %s was removed, it can not be named %q", r.Method, r.Pattern, name))
+	}
+	if other, ok := r.app.namedRoutes[name]; ok && other != r {
+		panic(fmt.Errorf("Route name %q is already used by %s %s", name, other.Method, other.Pattern))
+	}
+	if r.name != "" {
+		delete(r.app.namedRoutes, r.name)
+	}
+	r.name = name
+	r.app.namedRoutes[name] = r
+	return r
+}
+
+// RouteName returns the name of the route, or an empty string if it is not
+// named.
+func (r *Route) RouteName() string {
+	return r.name
+}
+
+// Tag returns the value of a tag set with the `Tag` route option.
+func (r *Route) Tag(key string) string {
+	return r.tags[key]
+}
+
+// URLFor builds the URL path of a named route. The parameters are given in pairs of
+// key and value, e.g. `app.URLFor("user.show", "id", 42)`. Parameters not used
+// by the route pattern are appended as the query string. The URL of a mounted
+// application starts with the prefix it is mounted under.
+func (app *Application) URLFor(name string, pairs ...interface{}) (string, error) {
+	app.mu.RLock()
+	r, ok := app.namedRoutes[name]
+	app.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("Route not found: %s", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("Odd number of parameters given for route %s", name)
+	}
+	params := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("Parameter name should be a string, got %v", pairs[i])
+		}
+		params[key] = fmt.Sprint(pairs[i+1])
+	}
+	u, err := buildURL(r.Pattern, params)
+	if err != nil {
+		return "", err
+	}
+	if prefix := app.urlPrefix(); prefix != "" {
+		u = (&url.URL{Path: prefix}).EscapedPath() + u
+	}
+	return u, nil
+}
+
+// buildURL fills the parameters of a route pattern with the values given and
+// escapes them. The used parameters are removed from the map, the remaining
+// ones are encoded as the query string.
+func buildURL(pattern string, params map[string]string) (string, error) {
+	var (
+		buf     bytes.Buffer
+		partidx int
+		omitted string
+	)
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != ':' && pattern[i] != '*' {
+			continue
+		}
+		buf.WriteString(pattern[partidx:i])
+
+		nameEnd := i + 1
+		for nameEnd < len(pattern) && pattern[nameEnd] != '/' && pattern[nameEnd] != '<' && pattern[nameEnd] != '?' {
+			nameEnd++
+		}
+		name := pattern[i+1 : nameEnd]
+		end := nameEnd
+		expr := ""
+		if end < len(pattern) && pattern[end] == '<' {
+			closing := constraintEnd(pattern, end)
+			expr = pattern[end+1 : closing]
+			if t, ok := paramTypes[expr]; ok {
+				expr = t
+			}
+			end = closing + 1
+		}
+
+		optional := end < len(pattern) && pattern[end] == '?'
+		if optional {
+			end++
+		}
+
+		value, ok := params[name]
+		if optional && (!ok || value == "") {
+			// The optional parameters end the pattern, the segment is
+			// omitted with its leading slash.
+			delete(params, name)
+			buf.Truncate(buf.Len() - 1)
+			if omitted == "" {
+				omitted = name
+			}
+			partidx = end
+			i = end - 1
+			continue
+		}
+		if !ok {
+			return "", fmt.Errorf("Missing parameter %q for route %s", name, pattern)
+		}
+		if omitted != "" {
+			return "", fmt.Errorf("Missing parameter %q for route %s", omitted, pattern)
+		}
+		delete(params, name)
+
+		if pattern[i] == '*' {
+			segments := strings.Split(value, "/")
+			for j, segment := range segments {
+				segments[j] = url.PathEscape(segment)
+			}
+			buf.WriteString(strings.Join(segments, "/"))
+		} else {
+			if value == "" {
+				return "", fmt.Errorf("Empty parameter %q for route %s", name, pattern)
+			}
+			if expr != "" {
+				if re, _ := compileConstraint(expr); !re.MatchString(value) {
+					return "", fmt.Errorf("Parameter %q does not match the constraint <%s> of route %s", name, expr, pattern)
+				}
+			}
+			buf.WriteString(url.PathEscape(value))
+		}
+		partidx = end
+		i = end - 1
+	}
+	buf.WriteString(pattern[partidx:])
+	if buf.Len() == 0 {
+		buf.WriteString("/")
+	}
+
+	if len(params) > 0 {
+		query := make(url.Values, len(params))
+		for key, value := range params {
+			query.Set(key, value)
+		}
+		buf.WriteString("?")
+		buf.WriteString(query.Encode())
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,365 @@
+package golf
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestURLFor(t *testing.T) {
+	app := New()
+	app.Get("/users/:id<int>", handler).Name("user.show")
+	app.Get("/users/:user/posts/:slug", handler).Name("post.show")
+	app.Get("/files/*filepath", handler).Name("files")
+	app.Group("/admin").Get("/", handler).Name("admin.index")
+
+	cases := []struct {
+		name     string
+		pairs    []interface{}
+		expected string
+	}{
+		{"user.show", []interface{}{"id", 42}, "/users/42"},
+		{"post.show", []interface{}{"user", "dinever", "slug", "hello world"}, "/users/dinever/posts/hello%20world"},
+		{"post.show", []interface{}{"user", "a/b", "slug", "x", "page", 2}, "/users/a%2Fb/posts/x?page=2"},
+		{"files", []interface{}{"filepath", "css/main file.css"}, "/files/css/main%20file.css"},
+		{"admin.index", nil, "/admin"},
+	}
+	for _, c := range cases {
+		url, err := app.URLFor(c.name, c.pairs...)
+		assertNoError(t, err)
+		assertEqual(t, c.expected, url)
+	}
+}
+
+func TestURLForErrors(t *testing.T) {
+	app := New()
+	app.Get("/users/:id<int>", handler).Name("user.show")
+
+	_, err := app.URLFor("user.unknown", "id", 1)
+	assertError(t, err)
+	_, err = app.URLFor("user.show")
+	assertError(t, err)
+	_, err = app.URLFor("user.show", "id")
+	assertError(t, err)
+	_, err = app.URLFor("user.show", "id", "abc")
+	assertError(t, err)
+	_, err = app.URLFor("user.show", "id", "")
+	assertError(t, err)
+}
+
+func TestDuplicateRouteName(t *testing.T) {
+	app := New()
+	app.Get("/a", handler).Name("a")
+	defer func() {
+		if err := recover(); err == nil {
+			t.Errorf("Duplicate route name should raise an error.")
+		}
+	}()
+	app.Get("/b", handler).Name("a")
+}
+
+func TestURLForInTemplate(t *testing.T) {
+	app := New()
+	app.Get("/users/:id", handler).Name("user.show")
+	app.View.templateLoader["test"] = &TemplateManager{
+		Loader: &MapLoader{
+			"user.html":    `<a href="{{ url_for "user.show" "id" .id }}">`,
+			"unknown.html": `<a href="{{ url_for "user.unknown" }}">`,
+		},
+		FuncMap: app.View.FuncMap,
+	}
+	result, err := app.View.Render("test", "user.html", map[string]interface{}{"id": 7})
+	assertNoError(t, err)
+	assertEqual(t, `<a href="/users/7">`, result)
+
+	_, err = app.View.Render("test", "unknown.html", nil)
+	assertError(t, err)
+}
+
+func userHandler(ctx *Context) {}
+
+func TestRoutes(t *testing.T) {
+	app := New()
+	app.Get("/", handler)
+	app.Group("/users", RecoverMiddleware).Get("/:id", userHandler).Name("user.show")
+
+	routes := app.Routes()
+	assertEqual(t, 2, len(routes))
+	assertDeepEqual(t, RouteInfo{
+		Method:     "GET",
+		Pattern:    "/",
+		Handler:    funcName(handler),
+		Middleware: []string{},
+	}, routes[0])
+	assertDeepEqual(t, RouteInfo{
+		Method:     "GET",
+		Pattern:    "/users/:id",
+		Name:       "user.show",
+		Handler:    funcName(userHandler),
+		Middleware: []string{funcName(RecoverMiddleware)},
+	}, routes[1])
+
+	table := routes.String()
+	assertContains(t, table, `METHOD\s+PATTERN\s+NAME\s+HANDLER\s+MIDDLEWARE`)
+	assertContains(t, table, `GET\s+/users/:id\s+user.show\s+\S+golf.userHandler\s+\S+golf.RecoverMiddleware`)
+
+	b, err := routes.JSON()
+	assertNoError(t, err)
+	assertContains(t, string(b), `"pattern": "/users/:id"`)
+	assertContains(t, string(b), `"name": "user.show"`)
+}
+
+func TestRoutesHandler(t *testing.T) {
+	app := New()
+	app.Get("/debug/routes", RoutesHandler)
+
+	_, _, r, w := makeTestContext("GET", "/debug/routes")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "application/json", w.Header().Get("Content-Type"))
+	assertContains(t, w.Body.String(), `"handler": "\S+golf.RoutesHandler"`)
+}
+
+func TestRouteMiddleware(t *testing.T) {
+	app := New()
+	calls := 0
+	counter := func(next HandlerFunc) HandlerFunc {
+		calls++
+		return next
+	}
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			if ctx.Header("Authorization") == "" {
+				ctx.Abort(401)
+				return
+			}
+			next(ctx)
+		}
+	}
+	api := app.Group("/api", headerMiddleware("X-Order", "group"))
+	api.Post("/upload", func(ctx *Context) { ctx.Send("uploaded") },
+		With(headerMiddleware("X-Order", "route"), auth),
+		With(counter),
+		Named("upload"))
+	api.Get("/status", func(ctx *Context) { ctx.Send("ok") })
+	assertEqual(t, 1, calls)
+
+	_, _, r, w := makeTestContext("POST", "/api/upload")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 401, w.Code)
+
+	_, _, r, w = makeTestContext("POST", "/api/upload")
+	r.Header.Set("Authorization", "token")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "uploaded", w.Body.String())
+	assertDeepEqual(t, []string{"group", "route"}, w.Header()["X-Order"])
+	assertEqual(t, 1, calls)
+
+	_, _, r, w = makeTestContext("GET", "/api/status")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "ok", w.Body.String())
+	assertDeepEqual(t, []string{"group"}, w.Header()["X-Order"])
+
+	url, err := app.URLFor("upload")
+	assertNoError(t, err)
+	assertEqual(t, "/api/upload", url)
+	assertEqual(t, 4, len(app.Routes()[0].Middleware))
+}
+
+func TestRemoveRoute(t *testing.T) {
+	app := New()
+	removed := app.Get("/users/:id", func(ctx *Context) { ctx.Send("user") }).Name("user.show")
+	app.Get("/users/:id<int>/posts", func(ctx *Context) { ctx.Send("posts") })
+	app.Any("/files/*path", func(ctx *Context) { ctx.Send("files") })
+	app.Host("api.example.com").Get("/users/:id", func(ctx *Context) { ctx.Send("api user") })
+
+	assertError(t, app.RemoveRoute("GET", "/users/:name"))
+	assertError(t, app.RemoveRoute("GET", "/users"))
+	assertError(t, app.RemoveRoute("POST", "/users/:id"))
+	assertError(t, app.Host("www.example.com").RemoveRoute("GET", "/users/:id"))
+
+	assertNoError(t, app.RemoveRoute("GET", "/users/:id"))
+	assertNoError(t, app.RemoveRoute("*", "/files/*path"))
+	assertError(t, app.RemoveRoute("GET", "/users/:id"))
+
+	for path, code := range map[string]int{"/users/1": 404, "/users/1/posts": 200, "/files/a.txt": 404} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, code, w.Code)
+	}
+	assertEqual(t, "api user", makeTestHostRequest(app, "GET", "api.example.com", "/users/1"))
+	_, err := app.URLFor("user.show", "id", 1)
+	assertError(t, err)
+	assertEqual(t, 2, len(app.Routes()))
+
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Errorf("Naming a removed route should panic.")
+			}
+		}()
+		removed.Name("user.show")
+	}()
+	_, err = app.URLFor("user.show", "id", 1)
+	assertError(t, err)
+
+	assertNoError(t, app.Host("api.example.com").RemoveRoute("GET", "/users/:id"))
+	assertEqual(t, 1, len(app.Routes()))
+	assertEqual(t, "/users/:id<int>/posts", app.Routes()[0].Pattern)
+}
+
+func TestReplaceRoute(t *testing.T) {
+	app := New()
+	app.PanicOnRouteConflict = true
+	replaced := app.Get("/status", func(ctx *Context) { ctx.Send("v1") }).Name("status")
+	app.Group("/api").Get("/items/:id", func(ctx *Context) { ctx.Send("item v1") })
+
+	app.ReplaceRoute("GET", "/status", func(ctx *Context) { ctx.Send("v2") }, Named("status"))
+	app.Group("/api").ReplaceRoute("GET", "/items/:item", func(ctx *Context) { ctx.Send("item v2 " + ctx.Param("item")) })
+	app.ReplaceRoute("POST", "/status", func(ctx *Context) { ctx.Send("posted") })
+
+	for _, c := range []struct{ method, path, expected string }{
+		{"GET", "/status", "v2"},
+		{"GET", "/api/items/7", "item v2 7"},
+		{"POST", "/status", "posted"},
+	} {
+		_, _, r, w := makeTestContext(c.method, c.path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, c.expected, w.Body.String())
+	}
+	routes := app.Routes()
+	assertEqual(t, 3, len(routes))
+	assertEqual(t, "/api/items/:item", routes[1].Pattern)
+	url, err := app.URLFor("status")
+	assertNoError(t, err)
+	assertEqual(t, "/status", url)
+
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Errorf("Naming a replaced route should panic.")
+			}
+		}()
+		replaced.Name("status.v1")
+	}()
+	_, err = app.URLFor("status.v1")
+	assertError(t, err)
+}
+
+func TestFreeze(t *testing.T) {
+	app := New()
+	r := app.Get("/", handler)
+	app.Freeze()
+
+	for name, fn := range map[string]func(){
+		"Get":          func() { app.Get("/late", handler) },
+		"Group":        func() { app.Group("/api").Post("/late", handler) },
+		"ReplaceRoute": func() { app.ReplaceRoute("GET", "/", handler) },
+		"RemoveRoute":  func() { app.RemoveRoute("GET", "/") },
+		"Name":         func() { r.Name("index") },
+		"Use":          func() { app.Use(RecoverMiddleware) },
+		"Static":       func() { app.Static("/static", ".") },
+		"Mount":        func() { app.Mount("/sub", New()) },
+	} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("%s should panic once the application is frozen.", name)
+				}
+			}()
+			fn()
+		}()
+	}
+
+	_, _, req, w := makeTestContext("GET", "/")
+	app.ServeHTTP(w, req)
+	assertEqual(t, 200, w.Code)
+	assertEqual(t, 1, len(app.Routes()))
+}
+
+func TestConcurrentRouteRegistration(t *testing.T) {
+	app := New()
+	app.Get("/", func(ctx *Context) { ctx.Send("index") })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			pattern := fmt.Sprintf("/plugins/%d/:action", i)
+			app.Get(pattern, handler).Name(fmt.Sprintf("plugin.%d", i))
+			if i%2 == 0 {
+				app.RemoveRoute("GET", pattern)
+			}
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		_, _, r, w := makeTestContext("GET", "/")
+		app.ServeHTTP(w, r)
+		assertEqual(t, "index", w.Body.String())
+		_, _, r, w = makeTestContext("GET", fmt.Sprintf("/plugins/%d/run", i))
+		app.ServeHTTP(w, r)
+		app.URLFor("plugin.1", "action", "run")
+		app.Routes()
+	}
+	<-done
+
+	assertEqual(t, 101, len(app.Routes()))
+	_, _, r, w := makeTestContext("GET", "/plugins/1/run")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 200, w.Code)
+	_, _, r, w = makeTestContext("GET", "/plugins/2/run")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 404, w.Code)
+}
+
+func TestConcurrentConfiguration(t *testing.T) {
+	app := New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			app.Error(404, func(ctx *Context, data ...map[string]interface{}) { ctx.Send("missing") })
+			app.Use(func(next HandlerFunc) HandlerFunc { return next })
+			app.UseRouted(func(next HandlerFunc) HandlerFunc { return next })
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		_, _, r, w := makeTestContext("GET", "/missing")
+		app.ServeHTTP(w, r)
+		assertEqual(t, 404, w.Code)
+	}
+	<-done
+
+	_, _, r, w := makeTestContext("GET", "/missing")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "missing", w.Body.String())
+}
+
+func TestURLForOptionalParameters(t *testing.T) {
+	app := New()
+	app.Get("/archive/:year<int>?/:month?", handler).Name("archive")
+	app.Get("/:lang?", handler).Name("home")
+
+	cases := []struct {
+		name     string
+		pairs    []interface{}
+		expected string
+	}{
+		{"archive", nil, "/archive"},
+		{"archive", []interface{}{"year", 2017}, "/archive/2017"},
+		{"archive", []interface{}{"year", 2017, "month", "05"}, "/archive/2017/05"},
+		{"archive", []interface{}{"year", "", "page", 2}, "/archive?page=2"},
+		{"home", nil, "/"},
+		{"home", []interface{}{"lang", "fr"}, "/fr"},
+	}
+	for _, c := range cases {
+		url, err := app.URLFor(c.name, c.pairs...)
+		assertNoError(t, err)
+		assertEqual(t, c.expected, url)
+	}
+
+	_, err := app.URLFor("archive", "month", "05")
+	assertError(t, err)
+	_, err = app.URLFor("archive", "year", "abc")
+	assertError(t, err)
+}
@@ -1,7 +1,14 @@
 package golf
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
 )
 
 // HandlerFunc is the type of the handler function that Golf accepts.
@@ -10,148 +17,522 @@ type HandlerFunc func(ctx *Context)
 // ErrorHandlerFunc is the type of the function that handles error in Golf.
 type ErrorHandlerFunc func(ctx *Context, data ...map[string]interface{})
 
+// anyMethod is the method of the routes matching all the methods.
+const anyMethod = "*"
+
+// checkMethod validates the method of a route to register.
+func checkMethod(method string) string {
+	if method == "" || method == anyMethod || strings.IndexAny(method, " \t\r\n/") != -1 {
+		panic(fmt.Errorf("Invalid method %q", method))
+	}
+	return method
+}
+
 type router struct {
 	trees map[string]*node
+
+	// shared is true if the trees are shared with another router, the nodes
+	// are then copied before being modified.
+	shared bool
+
+	// Routers of the host specific routes.
+	hosts []*hostRouter
+
+	// The static mounts, matched with a `prefix/*filepath` pattern in the
+	// static tree.
+	static *node
+	mounts []*staticMount
 }
 
 func newRouter() *router {
 	return &router{trees: make(map[string]*node)}
 }
 
+// paramTypes maps the shorthand constraint types to their regular expressions,
+// e.g. `/users/:id<int>` is the same as `/users/:id<[0-9]+>`.
+var paramTypes = map[string]string{
+	"int":   `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"slug":  `[a-z0-9-]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// constraintEnd returns the index of the '>' closing the constraint opened at
+// path[start], nested angle brackets are allowed inside the expression.
+func constraintEnd(path string, start int) int {
+	depth := 0
+	for i := start; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// compileConstraint compiles the expression of a parameter constraint, the
+// expression must match the whole path segment.
+func compileConstraint(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
 func splitURLPath(path string) (parts []string, names map[string]int) {
 
 	var (
-		nameidx      = -1
 		partidx      int
 		paramCounter int
 	)
 
-	for i := 0; i < len(path); i++ {
+	names = make(map[string]int)
 
-		if names == nil {
-			names = make(map[string]int)
+	for i := 0; i < len(path); i++ {
+		if path[i] != ':' && path[i] != '*' {
+			continue
+		}
+		if i == 0 || path[i-1] != '/' {
+			panic(fmt.Errorf("Invalid parameter : or * should always be after / - %q", path))
+		}
+		if partidx != i {
+			parts = append(parts, path[partidx:i])
 		}
-		// recording name
-		if nameidx != -1 {
-			//found /
-			if path[i] == '/' {
-				names[path[nameidx:i]] = paramCounter
-				paramCounter++
 
-				nameidx = -1 // switch to normal recording
-				partidx = i
+		nameEnd := i + 1
+		for nameEnd < len(path) && path[nameEnd] != '/' && path[nameEnd] != '<' {
+			nameEnd++
+		}
+		part := path[i : i+1]
+		end := nameEnd
+		if end < len(path) && path[end] == '<' {
+			if path[i] == '*' {
+				panic(fmt.Errorf("Invalid parameter constraint, * parameters can not be constrained - %q", path))
 			}
-		} else {
-			if path[i] == ':' || path[i] == '*' {
-				if path[i-1] != '/' {
-					panic(fmt.Errorf("Invalid parameter : or * should always be after / - %q", path))
-				}
-				nameidx = i + 1
-				if partidx != i {
-					parts = append(parts, path[partidx:i])
-				}
-				parts = append(parts, path[i:nameidx])
+			closing := constraintEnd(path, end)
+			if closing == -1 {
+				panic(fmt.Errorf("Invalid parameter constraint, missing closing > - %q", path))
+			}
+			expr := path[end+1 : closing]
+			if t, ok := paramTypes[expr]; ok {
+				expr = t
+			}
+			if expr == "" {
+				panic(fmt.Errorf("Invalid parameter constraint, empty expression - %q", path))
+			}
+			if _, err := compileConstraint(expr); err != nil {
+				panic(fmt.Errorf("Invalid parameter constraint %q - %q: %v", path[end:closing+1], path, err))
+			}
+			part = ":<" + expr + ">"
+			end = closing + 1
+			if end < len(path) && path[end] != '/' {
+				panic(fmt.Errorf("Invalid parameter constraint, a constraint should end the path segment - %q", path))
 			}
 		}
-	}
 
-	if nameidx != -1 {
-		names[path[nameidx:]] = paramCounter
+		if path[i] == '*' && end != len(path) {
+			panic(fmt.Errorf("Invalid parameter, * parameters should always be at the end of the path - %q", path))
+		}
+
+		names[path[i+1:nameEnd]] = paramCounter
 		paramCounter++
-	} else if partidx < len(path) {
+		parts = append(parts, part)
+		partidx = end
+		i = end - 1
+	}
+
+	if partidx < len(path) {
 		parts = append(parts, path[partidx:])
 	}
 	return
 }
 
+var errRouteNotFound = errors.New("Can not find route")
+
+// FindRoute looks up the route matching the method and the path.
 func (router *router) FindRoute(method string, path string) (HandlerFunc, Parameter, error) {
+	return router.find(method, path, nil)
+}
+
+// find looks up a route like FindRoute, the values of the parameters are
+// captured into the given slice, which is reused if it is large enough.
+func (router *router) find(method string, path string, values []string) (HandlerFunc, Parameter, error) {
 	node := router.trees[method]
 	if node == nil {
-		return nil, Parameter{}, fmt.Errorf("Can not find route")
+		return nil, Parameter{}, errRouteNotFound
 	}
-	matchedNode, err := node.findRoute(path)
-	if err != nil {
-		return nil, Parameter{}, err
+	matchedNode, values := node.findRoute(path, values[:0])
+	if matchedNode == nil {
+		return nil, Parameter{}, errRouteNotFound
 	}
-	return matchedNode.handler, Parameter{node: matchedNode, path: path}, err
+	return matchedNode.handler, Parameter{node: matchedNode, values: values}, nil
 }
 
-func (router *router) AddRoute(method string, path string, handler HandlerFunc) {
+// FindCaseInsensitive looks up a route ignoring the case of the static parts,
+// and returns the path with the case of the route.
+func (router *router) FindCaseInsensitive(method string, path string) (string, bool) {
+	node := router.trees[method]
+	if node == nil {
+		return "", false
+	}
+	fixed, ok := node.findCaseInsensitive(path, make([]byte, 0, len(path)))
+	return string(fixed), ok
+}
+
+// AllowedMethods returns the methods having a route matching the path. OPTIONS
+// is always allowed when any other method matches, and HEAD is allowed along
+// with GET, since Golf answers them automatically.
+func (router *router) AllowedMethods(path string) []string {
+	var methods []string
+	for method, node := range router.trees {
+		if method == anyMethod {
+			continue
+		}
+		if matched, _ := node.findRoute(path, nil); matched != nil {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	if containsString(methods, "GET") && !containsString(methods, "HEAD") {
+		methods = append(methods, "HEAD")
+	}
+	if !containsString(methods, "OPTIONS") {
+		methods = append(methods, "OPTIONS")
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func containsString(s []string, str string) bool {
+	for _, v := range s {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteConflictError is returned when a route is registered on a method and
+// path already taken by another route.
+type RouteConflictError struct {
+	Method          string
+	Pattern         string
+	Source          string
+	ExistingPattern string
+	ExistingSource  string
+
+	// The path taken by both routes, it is one of the paths matched by a
+	// pattern with optional parameters.
+	Path string
+
+	// The Route replaced by the new one.
+	existing *Route
+}
+
+// Error method implements Error method of Go standard library "error".
+func (err *RouteConflictError) Error() string {
+	if err.Pattern == err.ExistingPattern {
+		return fmt.Sprintf("Route %s %s (%s) is already registered at %s", err.Method, err.Pattern, err.Source, err.ExistingSource)
+	}
+	msg := fmt.Sprintf("Route %s %s (%s) conflicts with %s %s (%s)", err.Method, err.Pattern, err.Source, err.Method, err.ExistingPattern, err.ExistingSource)
+	if err.Path != err.Pattern || err.Path != err.ExistingPattern {
+		msg += " on " + err.Path
+	}
+	return msg
+}
+
+// AddRoute registers a route. A *RouteConflictError is returned if the route
+// overrides a route registered before, the new route replaces the old one
+// anyway. The first conflict is returned if there are several of them.
+func (router *router) AddRoute(method string, path string, handler HandlerFunc) error {
+	if conflicts := router.addRoute(method, path, handler, nil); len(conflicts) > 0 {
+		return conflicts[0]
+	}
+	return nil
+}
+
+// addRoute registers a route and attaches the Route describing it to the node.
+// A route with optional parameters is registered on a node for each of the
+// paths it matches, a conflict is returned for each path already taken.
+func (router *router) addRoute(method string, path string, handler HandlerFunc, route *Route) []*RouteConflictError {
+	var conflicts []*RouteConflictError
+	source := callSite()
+	for _, expanded := range optionalPatterns(path) {
+		parts, names := splitURLPath(expanded)
+		rootNode, ok := router.trees[method]
+		if !ok {
+			rootNode = &node{}
+		} else if router.shared {
+			rootNode = rootNode.copyPath(parts)
+		}
+		router.trees[method] = rootNode
+
+		n := rootNode.addRoute(parts)
+		if n.handler != nil {
+			conflicts = append(conflicts, &RouteConflictError{
+				Method:          method,
+				Pattern:         path,
+				Source:          source,
+				ExistingPattern: n.pattern,
+				ExistingSource:  n.source,
+				Path:            expanded,
+				existing:        n.route,
+			})
+		}
+		n.handler = handler
+		n.names = names
+		n.pattern = path
+		n.source = source
+		n.route = route
+	}
+	return conflicts
+}
+
+// RemoveRoute removes the route registered with the method and the pattern,
+// it returns false if there is no such route.
+func (router *router) RemoveRoute(method string, path string) bool {
+	_, ok := router.removeRoute(method, path)
+	return ok
+}
+
+// removeRoute removes a route and returns the Route attached to it.
+func (router *router) removeRoute(method string, path string) (*Route, bool) {
 	var (
-		rootNode *node
-		ok       bool
+		route   *Route
+		removed bool
 	)
-	if rootNode, ok = router.trees[method]; !ok {
-		rootNode = &node{}
-		router.trees[method] = rootNode
+	for _, expanded := range optionalPatterns(path) {
+		rootNode := router.trees[method]
+		if rootNode == nil {
+			break
+		}
+		parts, _ := splitURLPath(expanded)
+		if router.shared {
+			rootNode = rootNode.copyPath(parts)
+		}
+		r, ok := rootNode.removeRoute(parts, path)
+		if !ok {
+			continue
+		}
+		route, removed = r, true
+		if rootNode.empty() {
+			delete(router.trees, method)
+		} else {
+			router.trees[method] = rootNode
+		}
 	}
+	return route, removed
+}
 
-	parts, names := splitURLPath(path)
-	rootNode.addRoute(parts, names, handler)
+// optionalPatterns expands a pattern ending with optional parameters into the
+// patterns of the paths it matches, e.g. `/posts/:page?` matches `/posts` and
+// `/posts/:page`. A pattern without optional parameters is returned as is.
+func optionalPatterns(path string) []string {
+	var (
+		patterns []string
+		expanded []byte
+		partidx  int
+	)
+	for i := 0; i < len(path); i++ {
+		if path[i] != ':' && path[i] != '*' {
+			continue
+		}
+		end := i + 1
+		for end < len(path) && path[end] != '/' && path[end] != '<' && path[end] != '?' {
+			end++
+		}
+		if end < len(path) && path[end] == '<' {
+			if closing := constraintEnd(path, end); closing != -1 {
+				end = closing + 1
+			}
+		}
+		if end == len(path) || path[end] != '?' {
+			if patterns != nil {
+				panic(fmt.Errorf("Invalid optional parameter, optional parameters should end the path - %q", path))
+			}
+			i = end - 1
+			continue
+		}
+		if path[i] == '*' {
+			panic(fmt.Errorf("Invalid optional parameter, * parameters can not be optional - %q", path))
+		}
+		if i == 0 || path[i-1] != '/' || (patterns != nil && path[partidx:i] != "/") {
+			panic(fmt.Errorf("Invalid optional parameter, optional parameters should end the path - %q", path))
+		}
+		if end+1 < len(path) && path[end+1] != '/' {
+			panic(fmt.Errorf("Invalid optional parameter, ? should end the path segment - %q", path))
+		}
 
-	if path == "/" {
-	} else if path[len(path)-1] != '/' {
-		parts, names := splitURLPath(path + "/")
-		rootNode.addRoute(parts, names, handler)
-	} else {
-		parts, names := splitURLPath(path[:len(path)-1])
-		rootNode.addRoute(parts, names, handler)
+		expanded = append(expanded, path[partidx:i]...)
+		if len(expanded) == 1 {
+			patterns = append(patterns, "/")
+		} else {
+			patterns = append(patterns, string(expanded[:len(expanded)-1]))
+		}
+		expanded = append(expanded, path[i:end]...)
+		partidx = end + 1
+		i = end
+	}
+	if patterns == nil {
+		return []string{path}
+	}
+	if partidx < len(path) {
+		panic(fmt.Errorf("Invalid optional parameter, optional parameters should end the path - %q", path))
+	}
+	return append(patterns, string(expanded))
+}
+
+// clone returns a copy of the router sharing its trees, the routes of the
+// copy, or of its host router with the given pattern, can be modified without
+// modifying the original router.
+func (r *router) clone(host string) *router {
+	c := &router{
+		trees:  make(map[string]*node, len(r.trees)),
+		shared: true,
+		hosts:  make([]*hostRouter, len(r.hosts)),
+	}
+	for method, rootNode := range r.trees {
+		c.trees[method] = rootNode
+	}
+	c.static = r.static
+	c.mounts = append([]*staticMount(nil), r.mounts...)
+	for i, h := range r.hosts {
+		if host != "" && h.pattern == host {
+			hc := *h
+			hc.router = h.router.clone("")
+			h = &hc
+		}
+		c.hosts[i] = h
+	}
+	return c
+}
+
+// The directory of the Golf source files, used for finding the call site of
+// a registration outside of Golf.
+var golfDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callSite returns the file and line of the first caller outside of Golf.
+func callSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != golfDir || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
 	}
-	rootNode.optimizeRoutes()
 }
 
 //Parameter holds the parameters matched in the route
 type Parameter struct {
-	*node         // matched node
-	path   string // url path given
-	cached map[string]string
+	*node           // matched node
+	values []string // values of the parameters, in the order of the pattern
+
+	host     *hostRouter // matched host pattern
+	hostname string      // hostname given
 }
 
 //Len returns number arguments matched in the provided URL
 func (p *Parameter) Len() int {
+	if p.node == nil {
+		return 0
+	}
+	if p.host != nil {
+		return len(p.names) + len(p.host.names)
+	}
 	return len(p.names)
 }
 
 //ByName returns the url parameter by name
 func (p *Parameter) ByName(name string) (string, error) {
-	if i, has := p.names[name]; has {
-		return p.findParam(i)
+	if p.node == nil {
+		return "", errParamNotFound
 	}
-	return "", fmt.Errorf("Parameter not found")
-}
-
-func lastIndexByte(s string, c byte) int {
-	for i := len(s) - 1; i >= 0; i-- {
-		if s[i] == c {
-			return i
+	if i, has := p.names[name]; has && i < len(p.values) {
+		return p.values[i], nil
+	}
+	if p.host != nil {
+		if value, has := p.host.param(p.hostname, name); has {
+			return value, nil
 		}
 	}
-	return -1
+	return "", errParamNotFound
 }
 
-//findParam walks up the matched node looking for parameters returns the last parameter
-func (p *Parameter) findParam(idx int) (string, error) {
-	index := len(p.names) - 1
-	urlPath := p.path
-	pathLen := len(p.path)
-	node := p.node
+var errParamNotFound = errors.New("Parameter not found")
 
-	for node != nil {
-		if node.text[0] == ':' {
-			ctn := lastIndexByte(urlPath, '/')
-			if ctn == -1 {
-				return "", fmt.Errorf("Parameter not found")
-			}
-			pathLen = ctn + 1
-			if index == idx {
-				return urlPath[pathLen:], nil
+// staticMount returns the static mount of the prefix, creating it if needed.
+// The mount returned is a copy which can be modified.
+func (router *router) staticMount(prefix string) *staticMount {
+	if router.static == nil {
+		router.static = &node{}
+	}
+	// The mount is matched by the prefix itself and the paths under it.
+	patterns := []string{prefix + "/*filepath"}
+	if prefix != "" {
+		patterns = append(patterns, prefix)
+	}
+	nodes := make([]*node, len(patterns))
+	for i, pattern := range patterns {
+		parts, _ := splitURLPath(pattern)
+		if router.shared {
+			router.static = router.static.copyPath(parts)
+		}
+		nodes[i] = router.static.addRoute(parts)
+		nodes[i].pattern = pattern
+	}
+
+	existing := nodes[0].mount
+	m := &staticMount{prefix: prefix, cache: newStaticCache(1024, time.Second)}
+	if existing != nil {
+		*m = *existing
+		m.roots = append([]*staticRoot(nil), m.roots...)
+		for i, mount := range router.mounts {
+			if mount == existing {
+				router.mounts[i] = m
 			}
-			index--
-		} else {
-			pathLen -= len(node.text)
 		}
-		urlPath = urlPath[0:pathLen]
-		node = node.parent
+	} else {
+		router.mounts = append(router.mounts, m)
+	}
+	for _, n := range nodes {
+		n.mount = m
+	}
+	return m
+}
+
+// findStatic returns the static mount with the longest prefix matching the
+// path, or nil.
+func (router *router) findStatic(path string, values []string) (*staticMount, []string) {
+	if router.static == nil {
+		return nil, values
+	}
+	n, values := router.static.findRoute(path, values[:0])
+	if n == nil {
+		return nil, values
+	}
+	return n.mount, values
+}
+
+// parentStatic returns the static mount with the longest prefix matching the
+// prefix of a mount, or nil.
+func (router *router) parentStatic(m *staticMount, values []string) *staticMount {
+	if m.prefix == "" {
+		return nil
 	}
-	return "", fmt.Errorf("Parameter not found")
+	parent, _ := router.findStatic(m.prefix[:strings.LastIndexByte(m.prefix, '/')+1], values)
+	return parent
 }
@@ -1,6 +1,8 @@
 package golf
 
 import (
+	"fmt"
+	"net/http"
 	"testing"
 )
 
@@ -146,30 +148,522 @@ func TestRouterWithOptionalEndingSlash(t *testing.T) {
 		{"GET", "/users/:user/received_events/public", "/users/dinever/received_events/public", nil},
 	}
 
-	router := newRouter()
+	app := New()
 	for _, route := range cases {
-		router.AddRoute(route.method, route.path, handler)
+		route := route
+		app.Get(route.path, func(ctx *Context) {
+			for key, expected := range route.params {
+				assertStringEqual(t, expected, ctx.Param(key))
+			}
+			ctx.Send("success")
+		})
 	}
 
 	for _, route := range cases {
-		_, param, err := router.FindRoute(route.method, route.testPath)
-		if err != nil {
+		_, _, r, w := makeTestContext(route.method, route.testPath)
+		app.ServeHTTP(w, r)
+		if w.Body.String() != "success" {
 			t.Errorf("Can not find route: %v", route.testPath)
 		}
+	}
 
-		for key, expected := range route.params {
+	router := app.loadRouter()
+	for _, route := range cases[1 : len(cases)-1] {
+		if _, _, err := router.FindRoute(route.method, route.testPath); err == nil {
+			t.Errorf("Router should not match the path with a different ending slash: %v", route.testPath)
+		}
+	}
+}
+
+func TestRouterWithConstraints(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/users/:id<\\d+>", func(ctx *Context) { ctx.Send("id") })
+	router.AddRoute("GET", "/users/:name<alpha>/profile", func(ctx *Context) { ctx.Send("profile") })
+	router.AddRoute("GET", "/users/:name", func(ctx *Context) { ctx.Send("name") })
+	router.AddRoute("GET", "/posts/:slug<[a-z0-9-]+>", handler)
+	router.AddRoute("GET", "/items/:id<int>/edit", handler)
+
+	cases := []struct {
+		path, param, value string
+	}{
+		{"/users/42", "id", "42"},
+		{"/users/dinever/profile", "name", "dinever"},
+		{"/users/new", "name", "new"},
+		{"/users/abc123", "name", "abc123"},
+		{"/posts/hello-world-2", "slug", "hello-world-2"},
+		{"/items/7/edit", "id", "7"},
+	}
+	for _, c := range cases {
+		_, param, err := router.FindRoute("GET", c.path)
+		if err != nil {
+			t.Errorf("Can not find route: %v", c.path)
+			continue
+		}
+		val, err := param.ByName(c.param)
+		assertNoError(t, err)
+		assertStringEqual(t, c.value, val)
+	}
+
+	for _, path := range []string{"/posts/Hello", "/items/seven/edit", "/users/42/profile"} {
+		if _, _, err := router.FindRoute("GET", path); err == nil {
+			t.Errorf("Should not match route: %v", path)
+		}
+	}
+}
+
+func TestConstraintBacktracking(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/a/:id<int>/x", handler)
+	router.AddRoute("GET", "/a/:name/y", handler)
+
+	_, param, err := router.FindRoute("GET", "/a/1/y")
+	assertNoError(t, err)
+	val, _ := param.ByName("name")
+	assertStringEqual(t, "1", val)
+}
+
+func TestSplitURLPathWithConstraints(t *testing.T) {
+	parts, names := splitURLPath("/users/:id<int>/posts/:slug<[a-z]{2,}>")
+	assertSliceEqual(t, []string{"/users/", ":<[0-9]+>", "/posts/", ":<[a-z]{2,}>"}, parts)
+	assertEqual(t, 0, names["id"])
+	assertEqual(t, 1, names["slug"])
+}
+
+func TestInvalidConstraints(t *testing.T) {
+	paths := []string{
+		"/users/:id<\\d+",
+		"/users/:id<[0-9>",
+		"/users/:id<>",
+		"/users/:id<\\d+>x",
+		"/files/*path<\\d+>",
+	}
+	for _, path := range paths {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Invalid constraint should raise an error: %v", path)
+				}
+			}()
+			newRouter().AddRoute("GET", path, handler)
+		}()
+	}
+}
+
+func TestRouterWithWildcard(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/files/*filepath", func(ctx *Context) { ctx.Send("files") })
+	router.AddRoute("GET", "/files/static/:name", func(ctx *Context) { ctx.Send("static") })
+	router.AddRoute("GET", "/repos/:owner/:repo/contents/*path", handler)
+
+	cases := []struct {
+		path   string
+		params map[string]string
+	}{
+		{"/files/a/b/c.txt", map[string]string{"filepath": "a/b/c.txt"}},
+		{"/files/", map[string]string{"filepath": ""}},
+		{"/files/static/", map[string]string{"filepath": "static/"}},
+		{"/files/static/logo.png", map[string]string{"name": "logo.png"}},
+		{"/files/static/img/logo.png", map[string]string{"filepath": "static/img/logo.png"}},
+		{"/repos/dinever/golf/contents/docs/README.md", map[string]string{"owner": "dinever", "repo": "golf", "path": "docs/README.md"}},
+	}
+	for _, c := range cases {
+		_, param, err := router.FindRoute("GET", c.path)
+		if err != nil {
+			t.Errorf("Can not find route: %v", c.path)
+			continue
+		}
+		for key, expected := range c.params {
+			val, err := param.ByName(key)
+			assertNoError(t, err)
+			assertStringEqual(t, expected, val)
+		}
+	}
+
+	if _, _, err := router.FindRoute("GET", "/files"); err == nil {
+		t.Errorf("Should not match route: /files")
+	}
+}
+
+func TestWildcardPriority(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/*path", func(ctx *Context) { ctx.Send("wildcard") })
+	router.AddRoute("GET", "/users/:id", func(ctx *Context) { ctx.Send("param") })
+	router.AddRoute("GET", "/about", func(ctx *Context) { ctx.Send("static") })
+
+	for path, expected := range map[string]string{
+		"/about":      "static",
+		"/users/1":    "param",
+		"/users/1/x":  "wildcard",
+		"/contact":    "wildcard",
+		"/about/team": "wildcard",
+	} {
+		h, _, err := router.FindRoute("GET", path)
+		if err != nil {
+			t.Errorf("Can not find route: %v", path)
+			continue
+		}
+		ctx, _, _, w := makeTestContext("GET", path)
+		h(ctx)
+		assertEqual(t, expected, w.Body.String())
+	}
+}
+
+func TestInvalidWildcard(t *testing.T) {
+	for _, path := range []string{"/files/*filepath/edit", "/files/*filepath/"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Wildcard not at the end should raise an error: %v", path)
+				}
+			}()
+			newRouter().AddRoute("GET", path, handler)
+		}()
+	}
+}
+
+func TestRouterWithManyChildren(t *testing.T) {
+	router := newRouter()
+	var patterns []string
+	for c := 0x20; c < 0x7f; c++ {
+		if c != ':' && c != '*' {
+			patterns = append(patterns, "/"+string(rune(c)))
+		}
+	}
+	for r := 0x4e00; r < 0x4e00+300; r++ {
+		patterns = append(patterns, "/"+string(rune(r))+"/page")
+	}
+	for i := 0; i < 2000; i++ {
+		patterns = append(patterns, fmt.Sprintf("/cms/page-%d", i))
+	}
+	for _, pattern := range patterns {
+		router.AddRoute("GET", pattern, handler)
+	}
+
+	for _, pattern := range patterns {
+		_, param, err := router.FindRoute("GET", pattern)
+		if err != nil {
+			t.Errorf("Can not find route: %v", pattern)
+			continue
+		}
+		assertStringEqual(t, pattern, param.pattern)
+	}
+
+	for _, path := range []string{"/一", "/䷿/page", "/cms/page-2000", "/\xff"} {
+		if _, _, err := router.FindRoute("GET", path); err == nil {
+			t.Errorf("Should not match route: %v", path)
+		}
+	}
+}
+
+func TestRouterWithUnicode(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/café", handler)
+	router.AddRoute("GET", "/cafè", handler)
+	router.AddRoute("GET", "/日本/東京", handler)
+	router.AddRoute("GET", "/日本/大阪", handler)
+	router.AddRoute("GET", "/日本語", handler)
+	router.AddRoute("GET", "/ß/:name", handler)
+	router.AddRoute("GET", "/über/*rest", handler)
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"/café", "/café", nil},
+		{"/cafè", "/cafè", nil},
+		{"/日本/東京", "/日本/東京", nil},
+		{"/日本/大阪", "/日本/大阪", nil},
+		{"/日本語", "/日本語", nil},
+		{"/ß/jürgen", "/ß/:name", map[string]string{"name": "jürgen"}},
+		{"/über/straße/🍺", "/über/*rest", map[string]string{"rest": "straße/🍺"}},
+	}
+	for _, c := range cases {
+		_, param, err := router.FindRoute("GET", c.path)
+		if err != nil {
+			t.Errorf("Can not find route: %v", c.path)
+			continue
+		}
+		assertStringEqual(t, c.pattern, param.pattern)
+		for key, expected := range c.params {
+			val, err := param.ByName(key)
+			assertNoError(t, err)
+			assertStringEqual(t, expected, val)
+		}
+	}
+
+	for _, path := range []string{"/caf", "/cafe", "/caf\xc3", "/日本", "/日本/京都", "/ß"} {
+		if _, _, err := router.FindRoute("GET", path); err == nil {
+			t.Errorf("Should not match route: %v", path)
+		}
+	}
+
+	fixed, ok := router.FindCaseInsensitive("GET", "/CAFÉ")
+	assertEqual(t, true, ok)
+	assertEqual(t, "/café", fixed)
+	fixed, ok = router.FindCaseInsensitive("GET", "/CAFÈ")
+	assertEqual(t, true, ok)
+	assertEqual(t, "/cafè", fixed)
+	_, ok = router.FindCaseInsensitive("GET", "/SS/x")
+	assertEqual(t, false, ok)
+}
+
+func TestRouterRemoveRoute(t *testing.T) {
+	router := newRouter()
+	patterns := []string{"/users", "/users/:id", "/users/:id<int>/posts", "/users/new", "/uploads/*path", "/über"}
+	for _, pattern := range patterns {
+		router.AddRoute("GET", pattern, handler)
+	}
+
+	assertEqual(t, false, router.RemoveRoute("GET", "/users/:name"))
+	assertEqual(t, false, router.RemoveRoute("GET", "/use"))
+	assertEqual(t, false, router.RemoveRoute("POST", "/users"))
+	assertEqual(t, true, router.RemoveRoute("GET", "/users/new"))
+	assertEqual(t, false, router.RemoveRoute("GET", "/users/new"))
+
+	_, param, err := router.FindRoute("GET", "/users/new")
+	assertNoError(t, err)
+	assertStringEqual(t, "/users/:id", param.pattern)
+
+	assertEqual(t, true, router.RemoveRoute("GET", "/users/:id"))
+	_, _, err = router.FindRoute("GET", "/users/new")
+	assertError(t, err)
+	_, _, err = router.FindRoute("GET", "/users/7/posts")
+	assertNoError(t, err)
+
+	for _, pattern := range []string{"/users", "/users/:id<int>/posts", "/uploads/*path", "/über"} {
+		assertEqual(t, true, router.RemoveRoute("GET", pattern))
+	}
+	assertEqual(t, 0, len(router.trees))
+}
+
+func TestRouterClone(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/users/:id", handler)
+	router.host("api.example.com").AddRoute("GET", "/status", handler)
+
+	c := router.clone("")
+	c.AddRoute("GET", "/posts", handler)
+	c.RemoveRoute("GET", "/users/:id")
+	_, _, err := router.FindRoute("GET", "/users/1")
+	assertNoError(t, err)
+	_, _, err = router.FindRoute("GET", "/posts")
+	assertError(t, err)
+
+	c = router.clone("api.example.com")
+	c.host("api.example.com").RemoveRoute("GET", "/status")
+	_, _, err = router.host("api.example.com").FindRoute("GET", "/status")
+	assertNoError(t, err)
+}
+
+func TestOptionalPatterns(t *testing.T) {
+	cases := map[string][]string{
+		"/posts/:page?":                {"/posts", "/posts/:page"},
+		"/:lang?":                      {"/", "/:lang"},
+		"/archive/:year<int>?/:month?": {"/archive", "/archive/:year<int>", "/archive/:year<int>/:month"},
+		"/users/:id<[0-9]?>":           {"/users/:id<[0-9]?>"},
+		"/files/*path":                 {"/files/*path"},
+	}
+	for pattern, expected := range cases {
+		assertSliceEqual(t, expected, optionalPatterns(pattern))
+	}
+
+	for _, pattern := range []string{"/posts/:page?/comments", "/:a?/:b", "/files/*path?", "/posts/:page?x", "/posts-:page?"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Invalid optional parameter should raise an error: %v", pattern)
+				}
+			}()
+			optionalPatterns(pattern)
+		}()
+	}
+}
+
+func TestRouterWithOptionalParameters(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/posts/:page<int>?", handler)
+	router.AddRoute("GET", "/archive/:year?/:month?", handler)
+
+	cases := []struct {
+		path   string
+		params map[string]string
+		absent []string
+	}{
+		{"/posts", nil, []string{"page"}},
+		{"/posts/2", map[string]string{"page": "2"}, nil},
+		{"/archive", nil, []string{"year", "month"}},
+		{"/archive/2017", map[string]string{"year": "2017"}, []string{"month"}},
+		{"/archive/2017/05", map[string]string{"year": "2017", "month": "05"}, nil},
+	}
+	for _, c := range cases {
+		_, param, err := router.FindRoute("GET", c.path)
+		if err != nil {
+			t.Errorf("Can not find route: %v", c.path)
+			continue
+		}
+		for key, expected := range c.params {
 			val, err := param.ByName(key)
+			assertNoError(t, err)
+			assertStringEqual(t, expected, val)
+		}
+		for _, key := range c.absent {
+			_, err := param.ByName(key)
+			assertError(t, err)
+		}
+	}
+
+	for _, path := range []string{"/posts/abc", "/archive/2017/05/01"} {
+		if _, _, err := router.FindRoute("GET", path); err == nil {
+			t.Errorf("Should not match route: %v", path)
+		}
+	}
+
+	assertEqual(t, true, router.RemoveRoute("GET", "/posts/:page<int>?"))
+	_, _, err := router.FindRoute("GET", "/posts")
+	assertError(t, err)
+	_, _, err = router.FindRoute("GET", "/posts/2")
+	assertError(t, err)
+}
+
+func TestAllowedMethods(t *testing.T) {
+	router := newRouter()
+	router.AddRoute("GET", "/users/:id", handler)
+	router.AddRoute("DELETE", "/users/:id", handler)
+	router.AddRoute("POST", "/users", handler)
+
+	assertSliceEqual(t, []string{"DELETE", "GET", "HEAD", "OPTIONS"}, router.AllowedMethods("/users/1"))
+	assertSliceEqual(t, []string{"OPTIONS", "POST"}, router.AllowedMethods("/users"))
+	assertSliceEqual(t, nil, router.AllowedMethods("/posts"))
+}
+
+func TestRouteConflict(t *testing.T) {
+	router := newRouter()
+	assertNoError(t, router.AddRoute("GET", "/a/:id", handler))
+	assertNoError(t, router.AddRoute("GET", "/a/:id/x", handler))
+	assertNoError(t, router.AddRoute("GET", "/a/:name/y", handler))
+	assertNoError(t, router.AddRoute("GET", "/a/:id<int>", handler))
+	assertNoError(t, router.AddRoute("POST", "/a/:id", handler))
+
+	err := router.AddRoute("GET", "/a/:id", handler)
+	assertError(t, err)
+	conflict := err.(*RouteConflictError)
+	assertEqual(t, "/a/:id", conflict.ExistingPattern)
+	assertContains(t, conflict.Source, `router_test.go:\d+$`)
+	assertContains(t, conflict.ExistingSource, `router_test.go:\d+$`)
+	assertContains(t, err.Error(), `already registered`)
+
+	err = router.AddRoute("GET", "/a/:name", handler)
+	assertError(t, err)
+	assertContains(t, err.Error(), `GET /a/:name \(.*\) conflicts with GET /a/:id`)
+
+	_, param, _ := router.FindRoute("GET", "/a/foo")
+	val, _ := param.ByName("name")
+	assertStringEqual(t, "foo", val)
+}
+
+func TestEndingSlashRouteConflict(t *testing.T) {
+	router := newRouter()
+	assertNoError(t, router.AddRoute("GET", "/a", handler))
+	assertNoError(t, router.AddRoute("GET", "/a/", handler))
+	assertError(t, router.AddRoute("GET", "/a/", handler))
+}
+
+func TestRouterZeroAllocation(t *testing.T) {
+	router := newRouter()
+	for _, route := range githubAPI {
+		router.AddRoute(route.method, route.path, handler)
+	}
+	router.AddRoute("GET", "/files/*filepath", handler)
+	router.AddRoute("GET", "/items/:id<int>", handler)
+
+	values := make([]string, 0, 8)
+	for _, path := range []string{
+		"/authorizations",
+		"/applications/12345/tokens/67890",
+		"/repos/dinever/golf/events",
+		"/files/css/main.css",
+		"/items/42",
+	} {
+		allocs := testing.AllocsPerRun(100, func() {
+			_, param, err := router.find("GET", path, values)
 			if err != nil {
-				t.Errorf("Can not retrieve parameter from route %v: %v", route.testPath, key)
-			} else {
-				assertStringEqual(t, expected, val)
+				t.Fatalf("Can not find route: %v", path)
 			}
-			val, err = param.ByName(key)
-			if err != nil {
-				t.Errorf("Can not retrieve parameter from route %v: %v", route.testPath, key)
-			} else {
-				assertStringEqual(t, expected, val)
+			param.ByName("repo")
+		})
+		if allocs != 0 {
+			t.Errorf("Routing %v should not allocate, got %v allocations", path, allocs)
+		}
+	}
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func TestServeHTTPZeroAllocation(t *testing.T) {
+	app := New()
+	app.Get("/", handler)
+	app.Get("/repos/:owner/:repo/events", func(ctx *Context) {
+		ctx.Param("owner")
+		ctx.Param("repo")
+	})
+	w := &discardResponseWriter{header: make(http.Header)}
+	for _, path := range []string{"/", "/repos/dinever/golf/events"} {
+		r := makeTestHTTPRequest(nil, "GET", path)
+		app.ServeHTTP(w, r)
+		allocs := testing.AllocsPerRun(100, func() {
+			app.ServeHTTP(w, r)
+		})
+		if allocs != 0 {
+			t.Errorf("Serving %v should not allocate, got %v allocations", path, allocs)
+		}
+	}
+}
+
+func benchmarkRoutes(b *testing.B, routes []route) {
+	router := newRouter()
+	for _, route := range githubAPI {
+		router.AddRoute(route.method, route.path, handler)
+	}
+	values := make([]string, 0, 8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, route := range routes {
+			_, param, _ := router.find(route.method, route.testPath, values)
+			for key := range route.params {
+				param.ByName(key)
 			}
 		}
 	}
 }
+
+func BenchmarkStaticRoutes(b *testing.B) {
+	var routes []route
+	for _, route := range githubAPI {
+		if len(route.params) == 0 && route.path == route.testPath {
+			routes = append(routes, route)
+		}
+	}
+	benchmarkRoutes(b, routes)
+}
+
+func BenchmarkParamRoutes(b *testing.B) {
+	var routes []route
+	for _, route := range githubAPI {
+		if len(route.params) > 0 {
+			routes = append(routes, route)
+		}
+	}
+	benchmarkRoutes(b, routes)
+}
+
+func BenchmarkGithubAPI(b *testing.B) {
+	benchmarkRoutes(b, githubAPI)
+}
@@ -0,0 +1,739 @@
+package golf
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticRoot is a file system the static files are served from.
+type staticRoot struct {
+	http.FileSystem
+
+	// The name describing the root in the diagnostics.
+	name string
+
+	// The directory of the root on the disk and its resolved path, if it is
+	// one, or the fs.FS whose symbolic links can be read.
+	dir      string
+	resolved string
+	links    fs.ReadLinkFS
+}
+
+func newStaticRoot(root interface{}) *staticRoot {
+	r := &staticRoot{}
+	switch fsys := root.(type) {
+	case string:
+		r.FileSystem, r.name, r.dir = http.Dir(fsys), fsys, filepath.Clean(fsys)
+	case http.Dir:
+		r.FileSystem, r.name, r.dir = fsys, string(fsys), filepath.Clean(string(fsys))
+	case http.FileSystem:
+		r.FileSystem, r.name = fsys, fmt.Sprintf("%T", fsys)
+	case fs.FS:
+		r.FileSystem, r.name = http.FS(fsys), fmt.Sprintf("%T", fsys)
+		r.links, _ = fsys.(fs.ReadLinkFS)
+	default:
+		panic(fmt.Errorf("Invalid static root, expected a directory path, an http.FileSystem or an fs.FS, got %T", root))
+	}
+	if r.dir != "" {
+		r.resolved, _ = resolveDir(r.dir)
+	}
+	return r
+}
+
+// resolveDir returns the absolute path of a directory without symbolic links.
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// contains reports whether a file of the root is inside of it once the
+// symbolic links are followed. The links of an fs.FS are followed within it,
+// a link to an absolute path escapes it. The roots whose links can not be
+// read contain all of their files.
+func (r *staticRoot) contains(name string) bool {
+	if r.links != nil {
+		return containsLinks(r.links, name)
+	}
+	if r.dir == "" {
+		return true
+	}
+	dir := r.resolved
+	if dir == "" {
+		// The directory did not exist when the root was registered.
+		var err error
+		if dir, err = resolveDir(r.dir); err != nil {
+			return false
+		}
+	}
+	file, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// The maximum number of symbolic links followed to resolve a file.
+const maxStaticLinks = 40
+
+// containsLinks follows the symbolic links of a file of an fs.FS one segment
+// at a time, and reports whether none of them leads out of it.
+func containsLinks(fsys fs.ReadLinkFS, name string) bool {
+	pending := strings.Split(name, "/")
+	resolved := "."
+	links := 0
+	for len(pending) > 0 {
+		segment := pending[0]
+		pending = pending[1:]
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." {
+				return false
+			}
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, segment)
+		info, err := fsys.Lstat(next)
+		if err != nil {
+			return false
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxStaticLinks {
+			return false
+		}
+		target, err := fsys.ReadLink(next)
+		if err != nil || filepath.IsAbs(target) || path.IsAbs(filepath.ToSlash(target)) {
+			return false
+		}
+		// The target is relative to the directory of the link.
+		pending = append(strings.Split(filepath.ToSlash(target), "/"), pending...)
+	}
+	return true
+}
+
+// staticMount holds the roots and the options of the static files served
+// under a URL prefix.
+type staticMount struct {
+	prefix        string
+	roots         []*staticRoot
+	cache         *staticCache
+	maxAge        time.Duration
+	immutable     bool
+	etag          bool
+	precompressed bool
+	index         []string
+	fallback      string
+
+	// The template of the directory listings, rendered with the default
+	// template if the file is empty.
+	listing       bool
+	listingLoader string
+	listingFile   string
+
+	// The deny rules of the files.
+	dotfiles       bool
+	deny           []string
+	followSymlinks bool
+}
+
+// StaticOption configures the static files served under a prefix.
+type StaticOption func(m *staticMount)
+
+// StaticMaxAge sets the max-age of the Cache-Control header sent with the
+// static files.
+func StaticMaxAge(maxAge time.Duration) StaticOption {
+	return func(m *staticMount) {
+		m.maxAge = maxAge
+	}
+}
+
+// StaticImmutable adds immutable to the Cache-Control header sent with the
+// static files, for assets whose name changes with their content.
+func StaticImmutable() StaticOption {
+	return func(m *staticMount) {
+		m.immutable = true
+	}
+}
+
+// StaticETag sends a strong ETag computed from the content of the static
+// files. It is computed once and cached until the file is modified.
+func StaticETag() StaticOption {
+	return func(m *staticMount) {
+		m.etag = true
+	}
+}
+
+// StaticPrecompressed serves the `.br` or `.gz` file next to a static file
+// instead of it, if there is one and the client accepts the encoding.
+func StaticPrecompressed() StaticOption {
+	return func(m *staticMount) {
+		m.precompressed = true
+	}
+}
+
+// StaticCache sets the number of file metadata entries cached for the
+// static files, and how long a missing file is remembered as missing. The
+// cache holds 1024 entries for one second by default, a size of 0 disables
+// it.
+func StaticCache(size int, ttl time.Duration) StaticOption {
+	return func(m *staticMount) {
+		m.cache = newStaticCache(size, ttl)
+	}
+}
+
+// StaticIndex sets the files served for the directories, e.g.
+// `golf.StaticIndex("index.html")`, the first one found is served. A request
+// for a directory without a trailing slash is redirected to the URL with one.
+func StaticIndex(names ...string) StaticOption {
+	return func(m *staticMount) {
+		m.index = append(m.index, names...)
+	}
+}
+
+// StaticFallback sets the file served for the GET and HEAD requests under the
+// prefix matching neither a static file nor a route, e.g. the `index.html` of
+// a single-page app routing on the client side.
+func StaticFallback(name string) StaticOption {
+	return func(m *staticMount) {
+		m.fallback = path.Clean("/" + name)
+	}
+}
+
+// StaticListing lists the content of the directories having no index file,
+// with the default listing template.
+func StaticListing() StaticOption {
+	return func(m *staticMount) {
+		m.listing = true
+	}
+}
+
+// StaticListingTemplate lists the content of the directories having no index
+// file with a template of the View. The template is given the request path
+// as "Path" and the `[]StaticDirEntry` of the directory as "Entries".
+func StaticListingTemplate(loaderName, file string) StaticOption {
+	return func(m *staticMount) {
+		m.listing = true
+		m.listingLoader = loaderName
+		m.listingFile = file
+	}
+}
+
+// StaticDotfiles serves the files and directories whose name starts with a
+// dot, such as `.well-known`. They are not served by default, so that `.git`
+// or `.env` are not exposed.
+func StaticDotfiles() StaticOption {
+	return func(m *staticMount) {
+		m.dotfiles = true
+	}
+}
+
+// StaticDeny denies the files matching any of the glob patterns, with the
+// syntax of `path.Match`. A pattern without a slash matches the name of any
+// file or directory of the path, e.g. `*.bak` or `node_modules`, a pattern
+// with one matches the path in the root of a file or of any of its
+// directories, e.g. `/private` or `/private/*` deny everything under
+// `/private`.
+func StaticDeny(patterns ...string) StaticOption {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Errorf("Invalid static deny pattern %q: %v", pattern, err))
+		}
+	}
+	return func(m *staticMount) {
+		for _, pattern := range patterns {
+			if strings.Contains(pattern, "/") {
+				pattern = "/" + strings.Trim(pattern, "/")
+			}
+			m.deny = append(m.deny, pattern)
+		}
+	}
+}
+
+// StaticFollowSymlinks serves the files whose symbolic links point outside of
+// the root, they are not served by default. The links are checked for the
+// directory roots and the fs.FS implementing fs.ReadLinkFS, such as os.DirFS.
+func StaticFollowSymlinks() StaticOption {
+	return func(m *staticMount) {
+		m.followSymlinks = true
+	}
+}
+
+// StaticDirEntry is an entry of a directory listing.
+type StaticDirEntry struct {
+	Name    string
+	URL     string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+const dirListingTemplate = `<!DOCTYPE HTML><html><head>
+    <meta http-equiv="content-type" content="text/html; charset=utf-8">
+    <title>Index of {{ .Path }}</title>
+</head><body>
+<h1>Index of {{ .Path }}</h1>
+<ul>
+{{ range .Entries }}    <li><a href="{{ .URL }}">{{ .Name }}{{ if .IsDir }}/{{ end }}</a></li>
+{{ end }}</ul>
+</body></html>`
+
+// Static registers a root of static files served under the URL prefix. The
+// root is the path of a directory, an http.FileSystem or an fs.FS such as an
+// embed.FS, use `fs.Sub` to serve a subdirectory of it, e.g.
+//
+//	//go:embed public
+//	var public embed.FS
+//
+//	sub, _ := fs.Sub(public, "public")
+//	app.Static("/static", sub, golf.StaticMaxAge(24*time.Hour), golf.StaticETag())
+//
+// Several roots can be registered for the same prefix, a file is looked up in
+// each of them in the order of registration. The options apply to all the
+// roots of the prefix. The longest prefix matching a request is tried first,
+// then the shorter ones.
+func (app *Application) Static(url string, root interface{}, options ...StaticOption) {
+	r := newStaticRoot(root)
+	prefix := strings.TrimRight(url, "/")
+	if prefix != "" && prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	if strings.ContainsAny(prefix, ":*") {
+		panic(fmt.Errorf("Invalid static prefix, : and * are not allowed - %q", url))
+	}
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.checkFrozen()
+	router := app.loadRouter().clone("")
+	m := router.staticMount(prefix)
+	m.roots = append(m.roots, r)
+	for _, option := range options {
+		option(m)
+	}
+	app.router.Store(router)
+}
+
+// Serves the static file matching the request if there is one.
+func (app *Application) serveStatic(ctx *Context) bool {
+	router := app.loadRouter()
+	reqPath := ctx.Request.URL.Path
+	m, values := router.findStatic(reqPath, ctx.paramValues)
+	ctx.paramValues = values
+	for m != nil {
+		name, ok := staticName(reqPath[len(m.prefix):])
+		if ok && m.serve(ctx, name) {
+			return true
+		}
+		m = router.parentStatic(m, values)
+	}
+	return false
+}
+
+// staticName returns the name of the file requested by the path under a
+// prefix. It returns false if the path is trying to go up with a `..` segment
+// or contains a backslash or a NUL character, instead of cleaning it.
+func staticName(p string) (string, bool) {
+	if strings.ContainsAny(p, "\\\x00") {
+		return "", false
+	}
+	if strings.Contains(p, "..") {
+		for _, segment := range strings.Split(p, "/") {
+			if segment == ".." {
+				return "", false
+			}
+		}
+	}
+	return path.Clean("/" + p), true
+}
+
+// allowed reports whether the file with the given name is allowed by the deny
+// rules of the mount.
+func (m *staticMount) allowed(name string) bool {
+	for start := 1; start < len(name); {
+		end := strings.IndexByte(name[start:], '/')
+		if end == -1 {
+			end = len(name)
+		} else {
+			end += start
+		}
+		// The segment and the path of the directory or file it ends.
+		segment, ancestor := name[start:end], name[:end]
+		start = end + 1
+
+		if !m.dotfiles && strings.HasPrefix(segment, ".") {
+			return false
+		}
+		for _, pattern := range m.deny {
+			target := segment
+			if pattern[0] == '/' {
+				target = ancestor
+			}
+			if matched, _ := path.Match(pattern, target); matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Serves the fallback file of the longest prefix matching the request which
+// has one.
+func (app *Application) serveFallback(ctx *Context) bool {
+	if ctx.Request.Method != "GET" && ctx.Request.Method != "HEAD" {
+		return false
+	}
+	router := app.loadRouter()
+	m, values := router.findStatic(ctx.Request.URL.Path, ctx.paramValues)
+	ctx.paramValues = values
+	for m != nil {
+		if m.fallback != "" {
+			for i, root := range m.roots {
+				if m.serveFrom(ctx, i, root, m.fallback) {
+					return true
+				}
+			}
+		}
+		m = router.parentStatic(m, values)
+	}
+	return false
+}
+
+// The encodings of the precompressed files, by order of preference.
+var precompressedEncodings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// serve serves the file with the given name from the first root having it,
+// or the index or the listing of the directory. It returns false if there is
+// no such file.
+func (m *staticMount) serve(ctx *Context, name string) bool {
+	for i, root := range m.roots {
+		if m.serveFrom(ctx, i, root, name) {
+			return true
+		}
+	}
+	for i, root := range m.roots {
+		for _, index := range m.index {
+			if f, info, ok := m.open(i, root, path.Join(name, index)); ok {
+				if m.redirectDir(ctx) {
+					f.Close()
+				} else {
+					m.serveFile(ctx, i, root, path.Join(name, index), f, info)
+				}
+				return true
+			}
+		}
+	}
+	if m.listing {
+		for _, root := range m.roots {
+			if m.serveListing(ctx, root, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redirectDir redirects a request for a directory to the URL ending with a
+// slash, so that the relative links of the page resolve in the directory. It
+// returns false if the URL already ends with one.
+func (m *staticMount) redirectDir(ctx *Context) bool {
+	if strings.HasSuffix(ctx.Request.URL.Path, "/") {
+		return false
+	}
+	target := (&url.URL{Path: path.Base(ctx.Request.URL.Path) + "/"}).String()
+	if ctx.Request.URL.RawQuery != "" {
+		target += "?" + ctx.Request.URL.RawQuery
+	}
+	ctx.Redirect301(target)
+	return true
+}
+
+// serveListing renders the listing of a directory of the root, it returns
+// false if there is no such directory.
+func (m *staticMount) serveListing(ctx *Context, root *staticRoot, name string) bool {
+	if !m.allowed(name) || (!m.followSymlinks && !root.contains(name)) {
+		return false
+	}
+	f, err := root.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.IsDir() {
+		return false
+	}
+	if m.redirectDir(ctx) {
+		return true
+	}
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		return false
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	entries := make([]StaticDirEntry, 0, len(infos))
+	for _, info := range infos {
+		entryName := path.Join(name, info.Name())
+		if !m.allowed(entryName) || (info.Mode()&os.ModeSymlink != 0 && !m.followSymlinks && !root.contains(entryName)) {
+			continue
+		}
+		entry := StaticDirEntry{
+			Name:    info.Name(),
+			URL:     (&url.URL{Path: info.Name()}).String(),
+			IsDir:   info.IsDir(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if entry.IsDir {
+			entry.URL += "/"
+		}
+		entries = append(entries, entry)
+	}
+
+	data := map[string]interface{}{
+		"Path":    ctx.Request.URL.Path,
+		"Entries": entries,
+	}
+	var content string
+	if m.listingFile == "" {
+		content, err = ctx.App.View.RenderFromString("", dirListingTemplate, data)
+	} else if _, ok := ctx.App.View.templateLoader[m.listingLoader]; !ok {
+		err = fmt.Errorf("Template loader not found: %s", m.listingLoader)
+	} else {
+		content, err = ctx.App.View.Render(m.listingLoader, m.listingFile, data)
+	}
+	if err != nil {
+		log.Printf("[Warning] Rendering the listing of %s: %v", ctx.Request.URL.Path, err)
+		ctx.App.handleError(ctx, 500)
+		return true
+	}
+	ctx.SetHeader("Content-Type", "text/html; charset=utf-8")
+	ctx.Send(content)
+	return true
+}
+
+// serveFrom serves the file with the given name from a root of the mount, it
+// returns false if there is no such file.
+func (m *staticMount) serveFrom(ctx *Context, i int, root *staticRoot, name string) bool {
+	f, info, ok := m.open(i, root, name)
+	if !ok {
+		return false
+	}
+	m.serveFile(ctx, i, root, name, f, info)
+	return true
+}
+
+// serveFile serves an opened file of a root of the mount, or its
+// precompressed variant, and closes it.
+func (m *staticMount) serveFile(ctx *Context, i int, root *staticRoot, name string, f http.File, info os.FileInfo) {
+	header := ctx.Response.Header()
+	servedName := name
+	if m.precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		acceptEncoding := ctx.Request.Header.Get("Accept-Encoding")
+		for _, encoding := range precompressedEncodings {
+			if !acceptsEncoding(acceptEncoding, encoding.name) {
+				continue
+			}
+			if cf, cinfo, ok := m.open(i, root, name+encoding.ext); ok {
+				f.Close()
+				f, info, servedName = cf, cinfo, name+encoding.ext
+				header.Set("Content-Encoding", encoding.name)
+				contentType := mime.TypeByExtension(path.Ext(name))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				header.Set("Content-Type", contentType)
+				break
+			}
+		}
+	}
+	defer f.Close()
+
+	if m.maxAge > 0 || m.immutable {
+		cacheControl := "public, max-age=" + strconv.Itoa(int(m.maxAge/time.Second))
+		if m.immutable {
+			cacheControl += ", immutable"
+		}
+		header.Set("Cache-Control", cacheControl)
+	}
+	if m.etag {
+		if etag, err := m.computeETag(staticCacheKey{i, servedName}, f, info); err == nil {
+			header.Set("ETag", etag)
+		}
+	}
+	http.ServeContent(ctx.Response, ctx.Request, name, info.ModTime(), f)
+}
+
+// open opens a regular file of a root of the mount allowed by its deny rules,
+// a file known to be missing is not looked up again until its cache entry
+// expires.
+func (m *staticMount) open(i int, root *staticRoot, name string) (http.File, os.FileInfo, bool) {
+	if !m.allowed(name) {
+		return nil, nil, false
+	}
+	key := staticCacheKey{i, name}
+	if entry, ok := m.cache.get(key); ok && entry.missing && time.Since(entry.checked) < m.cache.ttl {
+		return nil, nil, false
+	}
+	f, err := root.Open(name)
+	if err == nil && !m.followSymlinks && !root.contains(name) {
+		f.Close()
+		err = os.ErrNotExist
+	}
+	if err == nil {
+		info, err := f.Stat()
+		if err == nil && !info.IsDir() {
+			return f, info, true
+		}
+		f.Close()
+	}
+	m.cache.set(staticCacheEntry{key: key, missing: true, checked: time.Now()})
+	return nil, nil, false
+}
+
+// computeETag returns the strong ETag of a file, computing it if the file is
+// modified since it was cached. The file is read and rewound.
+func (m *staticMount) computeETag(key staticCacheKey, f http.File, info os.FileInfo) (string, error) {
+	if entry, ok := m.cache.get(key); ok && !entry.missing && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.etag, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	entry := staticCacheEntry{
+		key:     key,
+		etag:    `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+	m.cache.set(entry)
+	return entry.etag, nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header accepts the
+// encoding, explicitly or with `*`, with a non-zero quality.
+func acceptsEncoding(header, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		name := part
+		q := ""
+		if i := strings.IndexByte(part, ';'); i != -1 {
+			name, q = part[:i], strings.TrimSpace(part[i+1:])
+		}
+		name = strings.TrimSpace(name)
+		if name != encoding && name != "*" {
+			continue
+		}
+		rejected := false
+		if strings.HasPrefix(q, "q=") {
+			if value, err := strconv.ParseFloat(q[2:], 64); err == nil && value == 0 {
+				rejected = true
+			}
+		}
+		if name == encoding {
+			return !rejected
+		}
+		accepted = !rejected
+	}
+	return accepted
+}
+
+// staticCache is a least recently used cache of the metadata of the static
+// files, the files found missing and the ETags of the files found.
+type staticCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[staticCacheKey]*list.Element
+	lru     *list.List
+}
+
+// staticCacheKey identifies a file by the index of its root in the mount and
+// its name.
+type staticCacheKey struct {
+	root int
+	name string
+}
+
+type staticCacheEntry struct {
+	key     staticCacheKey
+	missing bool
+	checked time.Time
+	etag    string
+	modTime time.Time
+	size    int64
+}
+
+func newStaticCache(size int, ttl time.Duration) *staticCache {
+	return &staticCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[staticCacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *staticCache) get(key staticCacheKey) (staticCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return staticCacheEntry{}, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(staticCacheEntry), true
+}
+
+func (c *staticCache) set(entry staticCacheEntry) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[entry.key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(staticCacheEntry).key)
+	}
+}
@@ -0,0 +1,568 @@
+package golf
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+//go:embed testdata/static
+var testStatic embed.FS
+
+func makeTestStaticRequest(app *Application, path string) (int, string) {
+	_, _, r, w := makeTestContext("GET", path)
+	app.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestStaticFileSystems(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("dir a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("dir b"), 0644)
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+
+	embedded, err := fs.Sub(testStatic, "testdata/static")
+	assertNoError(t, err)
+
+	app := New()
+	app.Static("/static/", fstest.MapFS{"a.txt": {Data: []byte("map a")}})
+	app.Static("/static", dir)
+	app.Static("/static", embedded)
+	app.Static("/files", http.Dir(dir))
+	app.Get("/static/dynamic", func(ctx *Context) { ctx.Send("dynamic") })
+
+	cases := []struct {
+		path     string
+		code     int
+		expected string
+	}{
+		{"/static/a.txt", 200, "map a"},
+		{"/static/b.txt", 200, "dir b"},
+		{"/static/embedded.txt", 200, "embedded\n"},
+		{"/static/css/main.css", 200, "body { color: red; }\n"},
+		{"/files/b.txt", 200, "dir b"},
+		{"/static/dynamic", 200, "dynamic"},
+		{"/static/docs", 404, ""},
+		{"/static/missing.txt", 404, ""},
+	}
+	for _, c := range cases {
+		code, body := makeTestStaticRequest(app, c.path)
+		assertEqual(t, c.code, code)
+		if c.expected != "" {
+			assertEqual(t, c.expected, body)
+		}
+	}
+}
+
+func TestInvalidStaticRoot(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Errorf("Invalid static root should raise an error.")
+		}
+	}()
+	New().Static("/static", 42)
+}
+
+func TestStaticCacheControl(t *testing.T) {
+	app := New()
+	app.Static("/static", "testdata/static", StaticMaxAge(24*time.Hour))
+	app.Static("/assets", "testdata/static", StaticMaxAge(365*24*time.Hour), StaticImmutable())
+	app.Static("/plain", "testdata/static")
+
+	for path, expected := range map[string]string{
+		"/static/embedded.txt": "public, max-age=86400",
+		"/assets/embedded.txt": "public, max-age=31536000, immutable",
+		"/plain/embedded.txt":  "",
+	} {
+		_, _, r, w := makeTestContext("GET", path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, 200, w.Code)
+		assertEqual(t, expected, w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestStaticETag(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.js")
+	os.WriteFile(file, []byte("console.log(1)"), 0644)
+
+	app := New()
+	app.Static("/static", dir, StaticETag())
+
+	_, _, r, w := makeTestContext("GET", "/static/app.js")
+	app.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	assertContains(t, etag, `^"[0-9a-f]{32}"$`)
+
+	_, _, r, w = makeTestContext("GET", "/static/app.js")
+	r.Header.Set("If-None-Match", etag)
+	app.ServeHTTP(w, r)
+	assertEqual(t, 304, w.Code)
+
+	os.WriteFile(file, []byte("console.log(2)"), 0644)
+	os.Chtimes(file, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	_, _, r, w = makeTestContext("GET", "/static/app.js")
+	r.Header.Set("If-None-Match", etag)
+	app.ServeHTTP(w, r)
+	assertEqual(t, 200, w.Code)
+	assertEqual(t, "console.log(2)", w.Body.String())
+	assertNotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	app := New()
+	app.Static("/static", fstest.MapFS{
+		"app.js":        {Data: []byte("identity")},
+		"app.js.br":     {Data: []byte("brotli")},
+		"app.js.gz":     {Data: []byte("gzip")},
+		"style.css":     {Data: []byte("identity css")},
+		"style.css.gz":  {Data: []byte("gzip css")},
+		"orphan.txt.gz": {Data: []byte("orphan")},
+	}, StaticPrecompressed(), StaticETag())
+	app.Static("/plain", fstest.MapFS{
+		"app.js":    {Data: []byte("identity")},
+		"app.js.gz": {Data: []byte("gzip")},
+	})
+
+	cases := []struct {
+		path, acceptEncoding, body, encoding string
+	}{
+		{"/static/app.js", "gzip, deflate, br", "brotli", "br"},
+		{"/static/app.js", "gzip", "gzip", "gzip"},
+		{"/static/app.js", "br;q=0, gzip;q=0.5", "gzip", "gzip"},
+		{"/static/app.js", "*", "brotli", "br"},
+		{"/static/app.js", "*, br;q=0", "gzip", "gzip"},
+		{"/static/app.js", "", "identity", ""},
+		{"/static/app.js", "deflate", "identity", ""},
+		{"/static/style.css", "br, gzip", "gzip css", "gzip"},
+		{"/plain/app.js", "gzip", "identity", ""},
+	}
+	for _, c := range cases {
+		_, _, r, w := makeTestContext("GET", c.path)
+		if c.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", c.acceptEncoding)
+		}
+		app.ServeHTTP(w, r)
+		assertEqual(t, c.body, w.Body.String())
+		assertEqual(t, c.encoding, w.Header().Get("Content-Encoding"))
+		if c.path == "/plain/app.js" {
+			assertEqual(t, "", w.Header().Get("Vary"))
+		} else {
+			assertEqual(t, "Accept-Encoding", w.Header().Get("Vary"))
+		}
+	}
+
+	_, _, r, w := makeTestContext("GET", "/static/style.css")
+	r.Header.Set("Accept-Encoding", "gzip")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+	gzipETag := w.Header().Get("ETag")
+	_, _, r, w = makeTestContext("GET", "/static/style.css")
+	app.ServeHTTP(w, r)
+	assertEqual(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+	assertNotEqual(t, gzipETag, w.Header().Get("ETag"))
+
+	code, _ := makeTestStaticRequest(app, "/static/orphan.txt")
+	assertEqual(t, 404, code)
+}
+
+func TestStaticLongestPrefix(t *testing.T) {
+	app := New()
+	app.Static("/static", fstest.MapFS{
+		"a.txt":     {Data: []byte("static a")},
+		"img/b.txt": {Data: []byte("static b")},
+	})
+	app.Static("/static/img", fstest.MapFS{"a.txt": {Data: []byte("img a")}})
+	app.Static("/", fstest.MapFS{"static/img/c.txt": {Data: []byte("root c")}})
+
+	cases := []struct {
+		path     string
+		code     int
+		expected string
+	}{
+		{"/static/a.txt", 200, "static a"},
+		{"/static/img/a.txt", 200, "img a"},
+		{"/static/img/b.txt", 200, "static b"},
+		{"/static/img/c.txt", 200, "root c"},
+		{"/staticfoo/a.txt", 404, ""},
+	}
+	for i := 0; i < 10; i++ {
+		for _, c := range cases {
+			code, body := makeTestStaticRequest(app, c.path)
+			assertEqual(t, c.code, code)
+			if c.code == 200 {
+				assertEqual(t, c.expected, body)
+			}
+		}
+	}
+}
+
+func TestStaticPrecedence(t *testing.T) {
+	files := fstest.MapFS{"page": {Data: []byte("static")}}
+	app := New()
+	app.Static("/", files)
+	app.Get("/page", func(ctx *Context) { ctx.Send("dynamic") })
+
+	_, body := makeTestStaticRequest(app, "/page")
+	assertEqual(t, "static", body)
+
+	app.RoutesBeforeStatic = true
+	_, body = makeTestStaticRequest(app, "/page")
+	assertEqual(t, "dynamic", body)
+
+	app = New()
+	app.RoutesBeforeStatic = true
+	app.Static("/", files)
+	app.Get("/other", handler)
+	code, body := makeTestStaticRequest(app, "/page")
+	assertEqual(t, 200, code)
+	assertEqual(t, "static", body)
+}
+
+func TestStaticCacheEviction(t *testing.T) {
+	cache := newStaticCache(2, time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		cache.set(staticCacheEntry{key: staticCacheKey{0, name}, missing: true})
+	}
+	_, ok := cache.get(staticCacheKey{0, "a"})
+	assertEqual(t, false, ok)
+	_, ok = cache.get(staticCacheKey{0, "b"})
+	assertEqual(t, true, ok)
+
+	// b is now the most recently used entry.
+	cache.set(staticCacheEntry{key: staticCacheKey{0, "d"}, missing: true})
+	_, ok = cache.get(staticCacheKey{0, "b"})
+	assertEqual(t, true, ok)
+	_, ok = cache.get(staticCacheKey{0, "c"})
+	assertEqual(t, false, ok)
+	assertEqual(t, 2, cache.lru.Len())
+
+	cache = newStaticCache(0, time.Second)
+	cache.set(staticCacheEntry{key: staticCacheKey{0, "a"}, missing: true})
+	_, ok = cache.get(staticCacheKey{0, "a"})
+	assertEqual(t, false, ok)
+}
+
+func TestStaticMissingCache(t *testing.T) {
+	dir := t.TempDir()
+	app := New()
+	app.Static("/cached", dir, StaticCache(16, 50*time.Millisecond))
+	app.Static("/uncached", dir, StaticCache(0, 0))
+
+	code, _ := makeTestStaticRequest(app, "/cached/new.txt")
+	assertEqual(t, 404, code)
+	code, _ = makeTestStaticRequest(app, "/uncached/new.txt")
+	assertEqual(t, 404, code)
+
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0644)
+	code, _ = makeTestStaticRequest(app, "/cached/new.txt")
+	assertEqual(t, 404, code)
+	code, _ = makeTestStaticRequest(app, "/uncached/new.txt")
+	assertEqual(t, 200, code)
+
+	time.Sleep(60 * time.Millisecond)
+	code, _ = makeTestStaticRequest(app, "/cached/new.txt")
+	assertEqual(t, 200, code)
+}
+
+func TestStaticIndex(t *testing.T) {
+	app := New()
+	app.Static("/docs", fstest.MapFS{
+		"index.html":       {Data: []byte("docs index")},
+		"guide/index.htm":  {Data: []byte("guide index")},
+		"empty/readme.txt": {Data: []byte("readme")},
+		"q?dir/index.html": {Data: []byte("q index")},
+	}, StaticIndex("index.html", "index.htm"))
+
+	cases := []struct {
+		path     string
+		code     int
+		expected string
+	}{
+		{"/docs/", 200, "docs index"},
+		{"/docs/guide/", 200, "guide index"},
+		{"/docs/empty/", 404, ""},
+		{"/docs/index.html", 200, "docs index"},
+	}
+	for _, c := range cases {
+		code, body := makeTestStaticRequest(app, c.path)
+		assertEqual(t, c.code, code)
+		if c.code == 200 {
+			assertEqual(t, c.expected, body)
+		}
+	}
+
+	_, _, r, w := makeTestContext("GET", "/docs/guide?lang=en")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 301, w.Code)
+	assertEqual(t, "guide/?lang=en", w.Header().Get("Location"))
+
+	_, _, r, w = makeTestContext("GET", "/docs")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 301, w.Code)
+	assertEqual(t, "docs/", w.Header().Get("Location"))
+
+	_, _, r, w = makeTestContext("GET", "/docs/q%3Fdir")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 301, w.Code)
+	assertEqual(t, "q%3Fdir/", w.Header().Get("Location"))
+	code, body := makeTestStaticRequest(app, "/docs/q%3Fdir/")
+	assertEqual(t, 200, code)
+	assertEqual(t, "q index", body)
+}
+
+func TestStaticFallback(t *testing.T) {
+	app := New()
+	app.Static("/app", fstest.MapFS{
+		"index.html": {Data: []byte("<html>app</html>")},
+		"main.js":    {Data: []byte("main")},
+	}, StaticFallback("index.html"))
+	app.Get("/app/api/users", func(ctx *Context) { ctx.Send("users") })
+	app.Post("/app/api/orders", handler)
+
+	cases := []struct {
+		method   string
+		path     string
+		code     int
+		expected string
+	}{
+		{"GET", "/app/main.js", 200, "main"},
+		{"GET", "/app/settings/profile", 200, "<html>app</html>"},
+		{"HEAD", "/app/settings", 200, ""},
+		{"GET", "/app/api/users", 200, "users"},
+		{"POST", "/app/settings", 404, ""},
+		{"GET", "/other", 404, ""},
+	}
+	for _, c := range cases {
+		_, _, r, w := makeTestContext(c.method, c.path)
+		app.ServeHTTP(w, r)
+		assertEqual(t, c.code, w.Code)
+		if c.code == 200 && c.method == "GET" {
+			assertEqual(t, c.expected, w.Body.String())
+		}
+	}
+}
+
+func TestStaticListing(t *testing.T) {
+	files := fstest.MapFS{
+		"files/b.txt":       {Data: []byte("b")},
+		"files/a <x>.txt":   {Data: []byte("a")},
+		"files/sub/c.txt":   {Data: []byte("c")},
+		"indexed/index.txt": {Data: []byte("indexed")},
+	}
+	app := New()
+	app.Static("/pub", files, StaticListing(), StaticIndex("index.txt"))
+
+	code, body := makeTestStaticRequest(app, "/pub/files/")
+	assertEqual(t, 200, code)
+	assertContains(t, body, `<title>Index of /pub/files/</title>`)
+	assertContains(t, body, `<li><a href="a%20%3Cx%3E.txt">a &lt;x&gt;.txt</a></li>\s+<li><a href="b.txt">b.txt</a></li>\s+<li><a href="sub/">sub/</a></li>`)
+
+	_, body = makeTestStaticRequest(app, "/pub/indexed/")
+	assertEqual(t, "indexed", body)
+
+	_, _, r, w := makeTestContext("GET", "/pub/files")
+	app.ServeHTTP(w, r)
+	assertEqual(t, 301, w.Code)
+
+	app = New()
+	app.View.SetTemplateLoader("test", "testdata")
+	app.Static("/pub", files, StaticListingTemplate("test", "listing.html"))
+	code, body = makeTestStaticRequest(app, "/pub/files/sub/")
+	assertEqual(t, 200, code)
+	assertEqual(t, "/pub/files/sub/: c.txt (1 bytes)\n", body)
+
+	for _, option := range []StaticOption{
+		StaticListingTemplate("test", "missing.html"),
+		StaticListingTemplate("unknown", "listing.html"),
+	} {
+		app = New()
+		app.View.SetTemplateLoader("test", "testdata")
+		app.Static("/pub", files, option)
+		code, _ = makeTestStaticRequest(app, "/pub/files/")
+		assertEqual(t, 500, code)
+	}
+
+	app = New()
+	app.Static("/pub", files)
+	code, _ = makeTestStaticRequest(app, "/pub/files/")
+	assertEqual(t, 404, code)
+}
+
+func TestStaticName(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{"/a.txt", "/a.txt", true},
+		{"/css//main.css", "/css/main.css", true},
+		{"/./a.txt", "/a.txt", true},
+		{"", "/", true},
+		{"/a..b.txt", "/a..b.txt", true},
+		{"/../secret.txt", "", false},
+		{"/css/../../secret.txt", "", false},
+		{"/..", "", false},
+		{"/..\\secret.txt", "", false},
+		{"/a.txt\x00.png", "", false},
+	}
+	for _, c := range cases {
+		name, ok := staticName(c.path)
+		assertEqual(t, c.ok, ok)
+		assertEqual(t, c.expected, name)
+	}
+}
+
+func TestStaticTraversal(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	os.MkdirAll(filepath.Join(root, "css"), 0755)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("s3cr3t content"), 0644)
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(root, "css", "main.css"), []byte("css"), 0644)
+
+	app := New()
+	app.Static("/static", root)
+	app.Static("/embedded", fstest.MapFS{"a.txt": {Data: []byte("a")}})
+
+	for _, p := range []string{
+		"/static/../secret.txt",
+		"/static/css/../../secret.txt",
+		"/static/%2e%2e/secret.txt",
+		"/static/css/%2E%2E/%2E%2E/secret.txt",
+		"/static/..%2fsecret.txt",
+		"/static/..%5csecret.txt",
+		"/static/a.txt%00.png",
+		"/static/..",
+		"/embedded/../embedded/a.txt",
+	} {
+		_, _, r, w := makeTestContext("GET", p)
+		app.ServeHTTP(w, r)
+		assertEqual(t, 404, w.Code)
+		assertEqual(t, false, strings.Contains(w.Body.String(), "s3cr3t"))
+	}
+
+	code, body := makeTestStaticRequest(app, "/static/css//main.css")
+	assertEqual(t, 200, code)
+	assertEqual(t, "css", body)
+}
+
+func TestStaticDeny(t *testing.T) {
+	files := fstest.MapFS{
+		".env":                     {Data: []byte("SECRET=1")},
+		".git/config":              {Data: []byte("[core]")},
+		".well-known/security.txt": {Data: []byte("contact")},
+		"css/.hidden.css":          {Data: []byte("hidden")},
+		"a.txt":                    {Data: []byte("a")},
+		"a.txt.bak":                {Data: []byte("backup")},
+		"node_modules/x/index.js":  {Data: []byte("x")},
+		"private/key.pem":          {Data: []byte("key")},
+		"private/sub/b.txt":        {Data: []byte("b")},
+		"secrets/sub/c.txt":        {Data: []byte("c")},
+		"public/private/ok.txt":    {Data: []byte("ok")},
+	}
+	app := New()
+	app.Static("/static", files, StaticDeny("*.bak", "node_modules", "/private/*", "secrets/"), StaticListing())
+	app.Static("/dot", files, StaticDotfiles())
+
+	cases := []struct {
+		path string
+		code int
+	}{
+		{"/static/a.txt", 200},
+		{"/static/.env", 404},
+		{"/static/.git/config", 404},
+		{"/static/.git/", 404},
+		{"/static/css/.hidden.css", 404},
+		{"/static/.well-known/security.txt", 404},
+		{"/static/a.txt.bak", 404},
+		{"/static/node_modules/x/index.js", 404},
+		{"/static/private/key.pem", 404},
+		{"/static/private/sub/b.txt", 404},
+		{"/static/private/sub/", 404},
+		{"/static/secrets/sub/c.txt", 404},
+		{"/static/secrets/", 404},
+		{"/static/public/private/ok.txt", 200},
+		{"/dot/.well-known/security.txt", 200},
+		{"/dot/.env", 200},
+	}
+	for _, c := range cases {
+		code, _ := makeTestStaticRequest(app, c.path)
+		assertEqual(t, c.code, code)
+	}
+
+	_, body := makeTestStaticRequest(app, "/static/")
+	assertContains(t, body, `href="a.txt"`)
+	assertEqual(t, false, strings.Contains(body, ".env"))
+	assertEqual(t, false, strings.Contains(body, ".git"))
+	assertEqual(t, false, strings.Contains(body, ".bak"))
+	assertEqual(t, false, strings.Contains(body, "node_modules"))
+
+	assertPanic := func(f func()) {
+		defer func() {
+			assertNotEqual(t, nil, recover())
+		}()
+		f()
+	}
+	assertPanic(func() { StaticDeny("[a-") })
+}
+
+func TestStaticSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	os.MkdirAll(filepath.Join(root, "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, "outside"), 0755)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	os.WriteFile(filepath.Join(dir, "outside", "b.txt"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(root, "sub", "a.txt"), []byte("a"), 0644)
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "secret.txt")); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+	os.Symlink(filepath.Join(dir, "outside"), filepath.Join(root, "outside"))
+	os.Symlink(filepath.Join("..", "secret.txt"), filepath.Join(root, "up.txt"))
+	os.Symlink(filepath.Join("sub", "a.txt"), filepath.Join(root, "inside.txt"))
+	os.Symlink(filepath.Join("..", "sub", "a.txt"), filepath.Join(root, "sub", "back.txt"))
+
+	app := New()
+	app.Static("/static", root, StaticListing())
+	app.Static("/dirfs", os.DirFS(root), StaticListing())
+	app.Static("/follow", root, StaticFollowSymlinks())
+	app.Static("/followfs", os.DirFS(root), StaticFollowSymlinks())
+
+	for _, prefix := range []string{"/static", "/dirfs"} {
+		cases := []struct {
+			path string
+			code int
+		}{
+			{"/sub/a.txt", 200},
+			{"/inside.txt", 200},
+			{"/sub/back.txt", 200},
+			{"/secret.txt", 404},
+			{"/up.txt", 404},
+			{"/outside/b.txt", 404},
+			{"/outside/", 404},
+		}
+		for _, c := range cases {
+			code, _ := makeTestStaticRequest(app, prefix+c.path)
+			assertEqual(t, c.code, code)
+		}
+
+		_, body := makeTestStaticRequest(app, prefix+"/")
+		assertContains(t, body, `href="inside.txt"`)
+		assertEqual(t, false, strings.Contains(body, "secret"))
+		assertEqual(t, false, strings.Contains(body, "up.txt"))
+		assertEqual(t, false, strings.Contains(body, "outside"))
+	}
+	for _, prefix := range []string{"/follow", "/followfs"} {
+		for _, p := range []string{"/secret.txt", "/up.txt", "/outside/b.txt"} {
+			code, _ := makeTestStaticRequest(app, prefix+p)
+			assertEqual(t, 200, code)
+		}
+	}
+}
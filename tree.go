@@ -1,9 +1,10 @@
 package golf
 
 import (
-	"fmt"
+	"regexp"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
 type node struct {
@@ -11,13 +12,30 @@ type node struct {
 	names   map[string]int
 	handler HandlerFunc
 
-	parent *node
-	colon  *node
+	// The pattern and the call site of the route registered on this node.
+	pattern string
+	source  string
+	route   *Route
 
+	// The static mount of a node of the static tree.
+	mount *staticMount
+
+	colon    *node
+	wildcard *node
+
+	// constraints holds the parameter children with a constraint, they are
+	// tried in registration order before the unconstrained colon child.
+	constraints nodes
+	regexp      *regexp.Regexp
+
+	// children are the static children sorted by their text, no two of them
+	// start with the same character. The children starting with an ASCII
+	// byte are indexed by it in indices, the others follow them from the
+	// index wide on and are binary searched by their first character.
 	children nodes
 	start    byte
-	max      byte
-	indices  []uint8
+	indices  []uint16
+	wide     int
 }
 
 type nodes []*node
@@ -31,7 +49,41 @@ func (s nodes) Swap(i, j int) {
 }
 
 func (s nodes) Less(i, j int) bool {
-	return s[i].text[0] < s[j].text[0]
+	return s[i].text < s[j].text
+}
+
+// firstChar returns the length of the first character of s, which is a UTF-8
+// encoded rune or a single byte if s does not start with a valid encoding.
+func firstChar(s string) int {
+	if s[0] < utf8.RuneSelf {
+		return 1
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return size
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b,
+// which never ends in the middle of a character.
+func commonPrefix(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	if i == max {
+		return i
+	}
+	first := firstChar(a)
+	for i > first && !utf8.RuneStart(a[i]) {
+		i--
+	}
+	if i < first {
+		return 0
+	}
+	return i
 }
 
 func (n *node) matchNode(path string) (*node, int8, int) {
@@ -43,37 +95,88 @@ func (n *node) matchNode(path string) (*node, int8, int) {
 		return n.colon, 0, 0
 	}
 
+	if path == "*" {
+		if n.wildcard == nil {
+			n.wildcard = &node{text: "*"}
+		}
+		return n.wildcard, 0, 0
+	}
+
+	if path[0] == ':' {
+		for _, child := range n.constraints {
+			if child.text == path {
+				return child, 0, 0
+			}
+		}
+		re, err := compileConstraint(path[2 : len(path)-1])
+		if err != nil {
+			panic(err)
+		}
+		child := &node{text: path, regexp: re}
+		n.constraints = append(n.constraints, child)
+		return child, 0, 0
+	}
+
 	for i, child := range n.children {
-		if child.text[0] == path[0] {
+		j := commonPrefix(path, child.text)
+		if j == 0 {
+			continue
+		}
 
-			maxLength := len(child.text)
-			pathLength := len(path)
-			var pathCompare int8
+		if j < len(path) && j < len(child.text) {
+			newNode := &node{text: path[j:]}
+			ccNode := &node{text: path[0:j], children: nodes{child, newNode}}
+			child.text = child.text[j:]
+			ccNode.optimizeRoutes()
+			n.children[i] = ccNode
+			return newNode, 0, i
+		}
 
-			if pathLength > maxLength {
-				pathCompare = 1
-			} else if pathLength < maxLength {
-				maxLength = pathLength
-				pathCompare = -1
-			}
+		if len(path) > len(child.text) {
+			return child, 1, i
+		} else if len(path) < len(child.text) {
+			return child, -1, i
+		}
+		return child, 0, i
+	}
 
-			for j := 0; j < maxLength; j++ {
-				if path[j] != child.text[j] {
-					ccNode := &node{text: path[0:j], children: nodes{child, &node{text: path[j:]}}}
-					child.text = child.text[j:]
-					n.children[i] = ccNode
-					return ccNode.children[1], 0, i
+	return nil, 0, 0
+}
+
+// findChild returns the static child whose text starts with the first
+// character of the path, or nil.
+func (n *node) findChild(urlPath string) *node {
+	urlByte := urlPath[0]
+	if urlByte < utf8.RuneSelf {
+		if urlByte >= n.start {
+			if i := int(urlByte - n.start); i < len(n.indices) {
+				if idx := n.indices[i]; idx != 0 {
+					return n.children[idx-1]
 				}
 			}
-
-			return child, pathCompare, i
 		}
+		return nil
 	}
 
-	return nil, 0, 0
+	key := urlPath[:firstChar(urlPath)]
+	lo, hi := n.wide, len(n.children)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if n.children[mid].text < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(n.children) && strings.HasPrefix(n.children[lo].text, key) {
+		return n.children[lo]
+	}
+	return nil
 }
 
-func (n *node) addRoute(parts []string, names map[string]int, handler HandlerFunc) {
+// addRoute creates the nodes for the parts of a route and returns the node
+// where the handler of the route should be set.
+func (n *node) addRoute(parts []string) *node {
 
 	var (
 		tmpNode     *node
@@ -86,6 +189,7 @@ func (n *node) addRoute(parts []string, names map[string]int, handler HandlerFun
 		if currentNode == nil {
 			currentNode = &node{text: parts[0]}
 			n.children = append(n.children, currentNode)
+			n.optimizeRoutes()
 		} else if result == 1 {
 			parts[0] = parts[0][len(currentNode.text):]
 			tmpNode, result, i = currentNode.matchNode(parts[0])
@@ -96,6 +200,7 @@ func (n *node) addRoute(parts []string, names map[string]int, handler HandlerFun
 			tmpNode := &node{text: parts[0]}
 			currentNode.text = currentNode.text[len(tmpNode.text):]
 			tmpNode.children = nodes{currentNode}
+			tmpNode.optimizeRoutes()
 			n.children[i] = tmpNode
 			currentNode = tmpNode
 		}
@@ -103,75 +208,256 @@ func (n *node) addRoute(parts []string, names map[string]int, handler HandlerFun
 	}
 
 	if len(parts) == 1 {
-		currentNode.handler = handler
-		currentNode.names = names
-		return
+		return currentNode
 	}
 
-	currentNode.addRoute(parts[1:], names, handler)
+	return currentNode.addRoute(parts[1:])
 }
 
-func (n *node) findRoute(urlPath string) (*node, error) {
-
-	urlByte := urlPath[0]
-	pathLen := len(urlPath)
+// removeRoute removes the route registered with the given pattern split into
+// parts, the nodes left without any route are pruned. It returns the Route
+// attached to the removed route, and false if the pattern is not registered.
+func (n *node) removeRoute(parts []string, pattern string) (*Route, bool) {
+	if len(parts) == 0 {
+		if n.handler == nil || n.pattern != pattern {
+			return nil, false
+		}
+		route := n.route
+		n.handler = nil
+		n.names = nil
+		n.pattern = ""
+		n.source = ""
+		n.route = nil
+		return route, true
+	}
 
-	if urlByte >= n.start && urlByte <= n.max {
-		if i := n.indices[urlByte-n.start]; i != 0 {
-			matched := n.children[i-1]
-			nodeLen := len(matched.text)
-			if nodeLen < pathLen {
-				if matched.text == urlPath[:nodeLen] {
-					if matched, _ := matched.findRoute(urlPath[nodeLen:]); matched != nil {
-						return matched, nil
-					}
-				}
-			} else if matched.text == urlPath {
-				return matched, nil
+	part, rest := parts[0], parts[1:]
+	var child *node
+	switch {
+	case part == ":":
+		child = n.colon
+	case part == "*":
+		child = n.wildcard
+	case part[0] == ':':
+		for _, cNode := range n.constraints {
+			if cNode.text == part {
+				child = cNode
 			}
 		}
+	default:
+		child = n.findChild(part)
+		if child == nil || !strings.HasPrefix(part, child.text) {
+			return nil, false
+		}
+		if len(child.text) < len(part) {
+			rest = append([]string{part[len(child.text):]}, rest...)
+		}
+	}
+	if child == nil {
+		return nil, false
 	}
 
-	if n.colon != nil && pathLen != 0 {
-		i := strings.IndexByte(urlPath, '/')
-		if i > 0 {
-			if cNode, err := n.colon.findRoute(urlPath[i:]); cNode != nil {
-				return cNode, err
-			}
-		} else if n.colon.handler != nil {
-			return n.colon, nil
+	route, ok := child.removeRoute(rest, pattern)
+	if ok && child.empty() {
+		switch {
+		case child == n.colon:
+			n.colon = nil
+		case child == n.wildcard:
+			n.wildcard = nil
+		case child.regexp != nil:
+			n.constraints = n.constraints.without(child)
+		default:
+			n.children = n.children.without(child)
+			n.optimizeRoutes()
 		}
 	}
+	return route, ok
+}
 
-	return nil, fmt.Errorf("Can not find route")
+// empty reports whether no route is registered on the node or below it.
+func (n *node) empty() bool {
+	return n.handler == nil && len(n.children) == 0 && len(n.constraints) == 0 && n.colon == nil && n.wildcard == nil
 }
 
-func (n *node) optimizeRoutes() {
+// without returns the nodes except the given one, the order is kept.
+func (s nodes) without(n *node) nodes {
+	result := make(nodes, 0, len(s))
+	for _, cNode := range s {
+		if cNode != n {
+			result = append(result, cNode)
+		}
+	}
+	return result
+}
 
-	if len(n.children) > 0 {
-		sort.Sort(n.children)
-		for i := 0; i < len(n.indices); i++ {
-			n.indices[i] = 0
+// copyPath returns a copy of the node in which the nodes a route with the
+// given parts is registered on, or would be, are copied as well. The route can
+// then be added or removed from the copy without modifying the original tree,
+// the other nodes are shared.
+func (n *node) copyPath(parts []string) *node {
+	c := *n
+	c.children = append(nodes(nil), n.children...)
+	c.constraints = append(nodes(nil), n.constraints...)
+	if len(parts) == 0 {
+		return &c
+	}
+
+	part, rest := parts[0], parts[1:]
+	switch {
+	case part == ":":
+		if c.colon != nil {
+			c.colon = c.colon.copyPath(rest)
+		}
+	case part == "*":
+		if c.wildcard != nil {
+			c.wildcard = c.wildcard.copyPath(rest)
+		}
+	case part[0] == ':':
+		for i, cNode := range c.constraints {
+			if cNode.text == part {
+				c.constraints[i] = cNode.copyPath(rest)
+			}
 		}
+	default:
+		child := c.findChild(part)
+		if child == nil {
+			break
+		}
+		if !strings.HasPrefix(part, child.text) {
+			rest = nil
+		} else if len(child.text) < len(part) {
+			rest = append([]string{part[len(child.text):]}, rest...)
+		}
+		for i, cNode := range c.children {
+			if cNode == child {
+				c.children[i] = child.copyPath(rest)
+			}
+		}
+	}
+	return &c
+}
 
-		n.start = n.children[0].text[0]
-		n.max = n.children[len(n.children)-1].text[0]
+// findRoute looks up the node of the route matching the path, the values of
+// the parameters are appended to values in the order they appear.
+func (n *node) findRoute(urlPath string, values []string) (*node, []string) {
 
-		for i := 0; i < len(n.children); i++ {
-			cNode := n.children[i]
-			cNode.parent = n
+	pathLen := len(urlPath)
+	if pathLen == 0 {
+		if n.handler != nil || n.mount != nil {
+			return n, values
+		}
+		if n.wildcard != nil {
+			return n.wildcard, append(values, "")
+		}
+		return nil, values
+	}
 
-			cByte := int(cNode.text[0] - n.start)
-			if cByte >= len(n.indices) {
-				n.indices = append(n.indices, make([]uint8, cByte+1-len(n.indices))...)
+	if matched := n.findChild(urlPath); matched != nil {
+		nodeLen := len(matched.text)
+		if nodeLen <= pathLen && matched.text == urlPath[:nodeLen] {
+			if matched, matchedValues := matched.findRoute(urlPath[nodeLen:], values); matched != nil {
+				return matched, matchedValues
 			}
-			n.indices[cByte] = uint8(i + 1)
-			cNode.optimizeRoutes()
+		}
+	}
+
+	for _, cNode := range n.constraints {
+		if matched, matchedValues := cNode.findParamRoute(urlPath, values); matched != nil {
+			return matched, matchedValues
 		}
 	}
 
 	if n.colon != nil {
-		n.colon.parent = n
-		n.colon.optimizeRoutes()
+		if matched, matchedValues := n.colon.findParamRoute(urlPath, values); matched != nil {
+			return matched, matchedValues
+		}
+	}
+
+	if n.wildcard != nil {
+		return n.wildcard, append(values, urlPath)
+	}
+
+	return nil, values
+}
+
+// findParamRoute matches a parameter node against the first segment of the
+// given path, checking the constraint of the node if there is one.
+func (n *node) findParamRoute(urlPath string, values []string) (*node, []string) {
+	segment := urlPath
+	if i := strings.IndexByte(urlPath, '/'); i != -1 {
+		segment = urlPath[:i]
+	}
+	if segment == "" {
+		return nil, values
+	}
+	if n.regexp != nil && !n.regexp.MatchString(segment) {
+		return nil, values
+	}
+	return n.findRoute(urlPath[len(segment):], append(values, segment))
+}
+
+// findCaseInsensitive looks up a route like findRoute but compares the static
+// parts case-insensitively. The path of the route found is appended to buf.
+func (n *node) findCaseInsensitive(urlPath string, buf []byte) ([]byte, bool) {
+	if urlPath == "" {
+		return buf, n.handler != nil || n.wildcard != nil
+	}
+
+	for _, child := range n.children {
+		nodeLen := len(child.text)
+		if nodeLen <= len(urlPath) && strings.EqualFold(child.text, urlPath[:nodeLen]) {
+			if fixed, ok := child.findCaseInsensitive(urlPath[nodeLen:], append(buf, child.text...)); ok {
+				return fixed, true
+			}
+		}
+	}
+
+	segment := urlPath
+	if i := strings.IndexByte(urlPath, '/'); i != -1 {
+		segment = urlPath[:i]
+	}
+	if segment != "" {
+		params := n.constraints
+		if n.colon != nil {
+			params = append(params[:len(params):len(params)], n.colon)
+		}
+		for _, cNode := range params {
+			if cNode.regexp != nil && !cNode.regexp.MatchString(segment) {
+				continue
+			}
+			if fixed, ok := cNode.findCaseInsensitive(urlPath[len(segment):], append(buf, segment...)); ok {
+				return fixed, true
+			}
+		}
+	}
+
+	if n.wildcard != nil {
+		return append(buf, urlPath...), true
+	}
+	return nil, false
+}
+
+// optimizeRoutes sorts the static children of the node and indexes them, it
+// is called whenever the children of the node change.
+func (n *node) optimizeRoutes() {
+	sort.Sort(n.children)
+	n.indices = nil
+	n.wide = len(n.children)
+	if len(n.children) == 0 {
+		return
+	}
+	n.start = n.children[0].text[0]
+	for i, cNode := range n.children {
+		if cNode.text[0] >= utf8.RuneSelf {
+			if i < n.wide {
+				n.wide = i
+			}
+			continue
+		}
+		cByte := int(cNode.text[0] - n.start)
+		for cByte >= len(n.indices) {
+			n.indices = append(n.indices, 0)
+		}
+		n.indices[cByte] = uint16(i + 1)
 	}
 }